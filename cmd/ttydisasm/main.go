@@ -0,0 +1,46 @@
+// Command ttydisasm prints the text, control characters and escape
+// sequences in ttyrec files, one per line.
+//
+// Usage:
+//
+//	ttydisasm [-json] file.ttyrec[.gz|.bz2]...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/greensnark/go-footv/compfile"
+	"github.com/greensnark/go-footv/disasm"
+	"github.com/greensnark/go-footv/ttyrec"
+)
+
+func disassemble(filename string, format disasm.Format) error {
+	file, err := compfile.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return disasm.Disassemble(ttyrec.Reader(file), os.Stdout, format)
+}
+
+func main() {
+	jsonOut := flag.Bool("json", false, "print JSON lines instead of text")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ttydisasm [-json] file.ttyrec...")
+		os.Exit(2)
+	}
+
+	format := disasm.FormatText
+	if *jsonOut {
+		format = disasm.FormatJSON
+	}
+	for _, filename := range flag.Args() {
+		if err := disassemble(filename, format); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// csiOp describes a CSI sequence: its mnemonic, and the names and
+// default values of its parameters.
+type csiOp struct {
+	name     string
+	params   []string
+	defaults []int
+}
+
+func op(name string, params ...string) csiOp {
+	defaults := make([]int, len(params))
+	for i := range defaults {
+		defaults[i] = 1
+	}
+	return csiOp{name: name, params: params, defaults: defaults}
+}
+
+func op0(name string, params ...string) csiOp {
+	return csiOp{name: name, params: params, defaults: make([]int, len(params))}
+}
+
+// csiOps is keyed by private marker, intermediates and final byte.
+var csiOps = map[string]csiOp{
+	"@":   op("ICH", "n"),
+	"A":   op("CUU", "n"),
+	"B":   op("CUD", "n"),
+	"C":   op("CUF", "n"),
+	"D":   op("CUB", "n"),
+	"E":   op("CNL", "n"),
+	"F":   op("CPL", "n"),
+	"G":   op("CHA", "col"),
+	"H":   op("CUP", "row", "col"),
+	"I":   op("CHT", "n"),
+	"J":   op0("ED", "mode"),
+	"K":   op0("EL", "mode"),
+	"L":   op("IL", "n"),
+	"M":   op("DL", "n"),
+	"P":   op("DCH", "n"),
+	"S":   op("SU", "n"),
+	"T":   op("SD", "n"),
+	"X":   op("ECH", "n"),
+	"Z":   op("CBT", "n"),
+	"`":   op("HPA", "col"),
+	"a":   op("HPR", "n"),
+	"b":   op("REP", "n"),
+	"c":   op0("DA"),
+	"d":   op("VPA", "row"),
+	"e":   op("VPR", "n"),
+	"f":   op("HVP", "row", "col"),
+	"g":   op0("TBC", "mode"),
+	"h":   op0("SM"),
+	"l":   op0("RM"),
+	"n":   op0("DSR", "report"),
+	"r":   op0("DECSTBM", "top", "bottom"),
+	"s":   op0("DECSLRM/SCOSC", "left", "right"),
+	"t":   op0("XTWINOPS"),
+	"u":   op0("SCORC"),
+	"?J":  op0("DECSED", "mode"),
+	"?K":  op0("DECSEL", "mode"),
+	"?h":  op0("DECSET"),
+	"?l":  op0("DECRST"),
+	"?n":  op0("DECDSR", "report"),
+	">c":  op0("DA2"),
+	" q":  op0("DECSCUSR", "style"),
+	"!p":  op0("DECSTR"),
+	"\"q": op0("DECSCA", "protect"),
+}
+
+var modeNames = map[int]string{
+	1:    "DECCKM",
+	3:    "DECCOLM",
+	5:    "DECSCNM",
+	6:    "DECOM",
+	7:    "DECAWM",
+	12:   "cursor-blink",
+	25:   "DECTCEM",
+	47:   "altscreen",
+	69:   "DECLRMM",
+	1000: "mouse",
+	1002: "mouse-drag",
+	1006: "mouse-sgr",
+	1047: "altscreen",
+	1048: "save-cursor",
+	1049: "altscreen+save-cursor",
+	2004: "bracketed-paste",
+}
+
+var ansiModeNames = map[int]string{
+	4:  "IRM",
+	20: "LNM",
+}
+
+var escNames = map[string]string{
+	"7":  "DECSC",
+	"8":  "DECRC",
+	"=":  "DECKPAM",
+	">":  "DECKPNM",
+	"D":  "IND",
+	"E":  "NEL",
+	"H":  "HTS",
+	"M":  "RI",
+	"Z":  "DECID",
+	"\\": "ST",
+	"c":  "RIS",
+	"#8": "DECALN",
+	"%@": "UTF-8 off",
+	"%G": "UTF-8 on",
+	"%8": "UTF-8 on",
+}
+
+var charsetNames = map[byte]string{
+	'0': "DEC graphics",
+	'A': "UK",
+	'B': "ASCII",
+	'U': "null mapping",
+}
+
+// Describe returns a human-readable decoding of the element, such as
+// "CUP row=4 col=20" or "SGR bold fg=1". Text elements have no
+// description.
+func (e *Element) Describe() string {
+	switch e.Kind {
+	case CSI:
+		return e.describeCSI()
+	case Esc:
+		return describeEsc(string(e.Raw[1:]))
+	case OSC:
+		return describeOSC(string(e.payload()))
+	case String:
+		switch e.Raw[1] {
+		case 'P':
+			return "DCS"
+		case 'X':
+			return "SOS"
+		case '^':
+			return "PM"
+		default:
+			return "APC"
+		}
+	case Partial:
+		return "incomplete sequence"
+	}
+	return ""
+}
+
+func describeEsc(seq string) string {
+	if name, ok := escNames[seq]; ok {
+		return name
+	}
+	if len(seq) == 2 && strings.IndexByte("()*+", seq[0]) >= 0 {
+		g := strings.IndexByte("()*+", seq[0])
+		name, ok := charsetNames[seq[1]]
+		if !ok {
+			name = fmt.Sprintf("%q", seq[1:])
+		}
+		return fmt.Sprintf("SCS G%d=%s", g, name)
+	}
+	return ""
+}
+
+func describeOSC(body string) string {
+	num, arg := body, ""
+	if sep := strings.IndexByte(body, ';'); sep >= 0 {
+		num, arg = body[:sep], body[sep+1:]
+	}
+	switch num {
+	case "0":
+		return fmt.Sprintf("set icon name and title=%q", arg)
+	case "1":
+		return fmt.Sprintf("set icon name=%q", arg)
+	case "2":
+		return fmt.Sprintf("set title=%q", arg)
+	}
+	return "OSC " + num
+}
+
+func (e *Element) describeCSI() string {
+	if e.Malformed {
+		return "malformed CSI"
+	}
+	key := e.Intermediate + string(e.Final)
+	if e.Private != 0 {
+		key = string(e.Private) + key
+	}
+	if key == "m" {
+		return describeSGR(e.Params, e.Sub)
+	}
+	op, ok := csiOps[key]
+	if !ok {
+		return ""
+	}
+
+	parts := []string{op.name}
+	switch key {
+	case "h", "l", "?h", "?l":
+		names := ansiModeNames
+		if e.Private == '?' {
+			names = modeNames
+		}
+		for _, mode := range e.Params {
+			if name, ok := names[mode]; ok {
+				parts = append(parts, fmt.Sprintf("%s(%d)", name, mode))
+			} else {
+				parts = append(parts, fmt.Sprint(mode))
+			}
+		}
+	case "t":
+		parts = append(parts, e.paramString())
+	default:
+		for i, name := range op.params {
+			parts = append(parts, fmt.Sprintf("%s=%d", name, e.param(i, op.defaults[i])))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+var sgrNames = map[int]string{
+	0:  "reset",
+	1:  "bold",
+	2:  "dim",
+	3:  "italic",
+	4:  "underline",
+	5:  "blink",
+	7:  "inverse",
+	8:  "invisible",
+	9:  "strike",
+	21: "-bold",
+	22: "-bold-dim",
+	23: "-italic",
+	24: "-underline",
+	25: "-blink",
+	27: "-inverse",
+	28: "-invisible",
+	29: "-strike",
+	39: "fg=default",
+	49: "bg=default",
+}
+
+// describeSGR describes SGR parameters, with the colon-separated
+// sub-parameters in sub, if any.
+func describeSGR(params []int, sub [][]int) string {
+	parts := []string{"SGR"}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if p < 0 {
+			p = 0
+		}
+		if i < len(sub) && len(sub[i]) > 0 {
+			parts = append(parts, describeSGRSub(p, sub[i]))
+			continue
+		}
+		switch {
+		case sgrNames[p] != "":
+			parts = append(parts, sgrNames[p])
+		case p >= 30 && p <= 37:
+			parts = append(parts, fmt.Sprintf("fg=%d", p-30))
+		case p >= 40 && p <= 47:
+			parts = append(parts, fmt.Sprintf("bg=%d", p-40))
+		case p >= 90 && p <= 97:
+			parts = append(parts, fmt.Sprintf("fg=%d", p-90+8))
+		case p >= 100 && p <= 107:
+			parts = append(parts, fmt.Sprintf("bg=%d", p-100+8))
+		case (p == 38 || p == 48) && i+2 < len(params) && params[i+1] == 5:
+			parts = append(parts, fmt.Sprintf("%s=%d", sgrTarget(p), params[i+2]))
+			i += 2
+		case (p == 38 || p == 48) && i+4 < len(params) && params[i+1] == 2:
+			parts = append(parts, fmt.Sprintf("%s=#%02x%02x%02x", sgrTarget(p),
+				params[i+2], params[i+3], params[i+4]))
+			i += 4
+		default:
+			parts = append(parts, fmt.Sprintf("?%d", p))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeSGRSub describes an SGR parameter with sub-parameters, such
+// as 38:5:100 or 38:2::1:2:3, whose colour space id may be left out.
+func describeSGRSub(p int, sub []int) string {
+	value := func(i int) int {
+		if sub[i] < 0 {
+			return 0
+		}
+		return sub[i]
+	}
+	if p == 38 || p == 48 {
+		switch {
+		case sub[0] == 5 && len(sub) == 2:
+			return fmt.Sprintf("%s=%d", sgrTarget(p), value(1))
+		case sub[0] == 2 && (len(sub) == 4 || len(sub) == 5):
+			n := len(sub)
+			return fmt.Sprintf("%s=#%02x%02x%02x", sgrTarget(p),
+				value(n-3), value(n-2), value(n-1))
+		}
+	}
+	s := fmt.Sprintf("?%d", p)
+	for _, v := range sub {
+		s += ":" + paramValue(v)
+	}
+	return s
+}
+
+func sgrTarget(p int) string {
+	if p == 38 {
+		return "fg"
+	}
+	return "bg"
+}
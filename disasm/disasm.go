@@ -0,0 +1,284 @@
+// Package disasm breaks terminal output into text, control characters
+// and escape sequences, and describes each one, to help debug
+// playback.
+package disasm
+
+import (
+	"time"
+
+	"github.com/greensnark/go-footv/vt"
+)
+
+type state int
+
+const (
+	stGround state = iota
+	stEsc
+	stEscArg // ESC plus an intermediate, waiting for one more byte
+	stCSI
+	stString    // OSC or DCS-like string
+	stStringEsc // ESC inside a string, maybe the start of ST
+)
+
+// Disassembler splits terminal output into Elements. Sequences may
+// span frames. Each element is also written to a Tty, so that
+// elements the Tty doesn't handle can be flagged.
+type Disassembler struct {
+	tty   *vt.Tty
+	state state
+	cur   *Element
+	text  *Element
+
+	frame  int
+	time   time.Time
+	offset int64
+	emit   func(*Element)
+}
+
+// New returns a Disassembler that checks elements against a fresh
+// terminal of the default size.
+func New() *Disassembler {
+	d := &Disassembler{tty: vt.New(), frame: -1}
+	d.tty.Resizable = true
+	d.tty.Observer = d
+	return d
+}
+
+// Observe implements vt.DiagnosticObserver, attributing the terminal's
+// diagnostics to the element being written.
+func (d *Disassembler) Observe(diag vt.Diagnostic) {
+	if d.cur != nil && diag.Kind != vt.DiagnosticInfo {
+		d.cur.Unsupported = append(d.cur.Unsupported, diag.Name)
+	}
+}
+
+// Frame disassembles a frame body, calling emit with each complete
+// element. Elements passed to emit must not be retained after emit
+// returns; the text element at the end of the frame is emitted when
+// the frame ends, but a partial escape sequence is held until a later
+// frame completes it.
+func (d *Disassembler) Frame(index int, t time.Time, body []byte, emit func(*Element)) {
+	d.frame, d.time, d.emit = index, t, emit
+	for _, b := range body {
+		d.consume(b)
+		d.offset++
+	}
+	d.flushText()
+}
+
+// Flush emits any incomplete escape sequence at the end of the
+// stream.
+func (d *Disassembler) Flush(emit func(*Element)) {
+	d.emit = emit
+	d.flushText()
+	if d.state != stGround {
+		d.finish(Partial)
+	}
+}
+
+func (d *Disassembler) start(kind Kind) {
+	d.cur = &Element{
+		Time:   d.time,
+		Frame:  d.frame,
+		Offset: d.offset,
+		Kind:   kind,
+	}
+}
+
+// finish completes the current sequence, writes it to the terminal
+// and emits it.
+func (d *Disassembler) finish(kind Kind) {
+	e := d.cur
+	e.Kind = kind
+	d.tty.Write(e.Raw)
+	d.emit(e)
+	d.cur = nil
+	d.state = stGround
+}
+
+func (d *Disassembler) flushText() {
+	if d.text == nil {
+		return
+	}
+	text := d.text
+	d.text = nil
+	saved := d.cur
+	d.cur = text
+	d.tty.Write(text.Raw)
+	d.cur = saved
+	d.emit(text)
+}
+
+func (d *Disassembler) addText(b byte) {
+	if d.text == nil {
+		d.text = &Element{
+			Time:   d.time,
+			Frame:  d.frame,
+			Offset: d.offset,
+			Kind:   Text,
+		}
+	}
+	d.text.Raw = append(d.text.Raw, b)
+}
+
+func (d *Disassembler) control(b byte) {
+	d.flushText()
+	saved := d.cur
+	d.start(Control)
+	d.cur.Raw = []byte{b}
+	d.tty.Write(d.cur.Raw)
+	d.emit(d.cur)
+	d.cur = saved
+}
+
+func (d *Disassembler) consume(b byte) {
+	if d.state == stString {
+		d.consumeString(b)
+		return
+	}
+	if d.state == stStringEsc {
+		d.cur.Raw = append(d.cur.Raw, b)
+		if b == '\\' {
+			d.finish(d.cur.Kind)
+			return
+		}
+		// The ESC ended the string and starts a new sequence.
+		d.cur.Raw = d.cur.Raw[:len(d.cur.Raw)-2]
+		d.finish(d.cur.Kind)
+		d.consume(0x1b)
+		d.offset--
+		d.consume(b)
+		d.offset++
+		return
+	}
+
+	switch {
+	case b == 0x1b:
+		d.flushText()
+		if d.state != stGround {
+			d.finish(Partial)
+		}
+		d.start(Esc)
+		d.cur.Raw = append(d.cur.Raw, b)
+		d.state = stEsc
+		return
+	case b == 24 || b == 26: // CAN, SUB: abort any sequence
+		if d.state != stGround {
+			d.finish(Partial)
+		}
+		d.control(b)
+		return
+	case b < 0x20 || b == 0x7f:
+		d.control(b)
+		return
+	}
+
+	switch d.state {
+	case stGround:
+		d.addText(b)
+	case stEsc:
+		d.cur.Raw = append(d.cur.Raw, b)
+		switch b {
+		case '[':
+			d.cur.Kind = CSI
+			d.cur.Params = []int{-1}
+			d.state = stCSI
+		case ']':
+			d.cur.Kind = OSC
+			d.state = stString
+		case 'P', 'X', '^', '_':
+			d.cur.Kind = String
+			d.state = stString
+		case '(', ')', '*', '+', '-', '.', '/', '%', '#', ' ':
+			d.state = stEscArg
+		default:
+			d.finish(Esc)
+		}
+	case stEscArg:
+		d.cur.Raw = append(d.cur.Raw, b)
+		d.finish(Esc)
+	case stCSI:
+		d.consumeCSI(b)
+	}
+}
+
+// maxRawLen is the most bytes of an OSC string or CSI sequence kept in
+// Raw. The rest are counted in Dropped, but the terminator is kept.
+const maxRawLen = 512
+
+// consumeCSI parses a byte of a CSI sequence the way a VT parser does:
+// parameter bytes 0x30-0x3F, then intermediates 0x20-0x2F, then a final
+// byte 0x40-0x7E. C0 controls, ESC, CAN and SUB never reach here.
+func (d *Disassembler) consumeCSI(b byte) {
+	e := d.cur
+	if b >= 0x40 && b <= 0x7e {
+		e.Raw = append(e.Raw, b)
+		e.Final = b
+		d.finish(CSI)
+		return
+	}
+	if len(e.Raw) >= maxRawLen {
+		e.Dropped++
+		return
+	}
+	e.Raw = append(e.Raw, b)
+	switch {
+	case b >= 0x20 && b <= 0x2f:
+		e.Intermediate += string(b)
+	case b >= 0x80 || e.Intermediate != "":
+		// Not a CSI byte, or a parameter byte after an intermediate.
+		e.Malformed = true
+	case b >= '<' && b <= '?':
+		if len(e.Raw) == 3 {
+			e.Private = b
+		} else {
+			e.Malformed = true
+		}
+	case b == ';':
+		e.Params = append(e.Params, -1)
+		if e.Sub != nil {
+			e.Sub = append(e.Sub, nil)
+		}
+	case b == ':':
+		if e.Sub == nil {
+			e.Sub = make([][]int, len(e.Params))
+		}
+		last := len(e.Params) - 1
+		e.Sub[last] = append(e.Sub[last], -1)
+	default:
+		p := &e.Params[len(e.Params)-1]
+		if last := len(e.Sub) - 1; last >= 0 && len(e.Sub[last]) > 0 {
+			p = &e.Sub[last][len(e.Sub[last])-1]
+		}
+		if *p < 0 {
+			*p = 0
+		}
+		if *p < 1e6 {
+			*p = *p*10 + int(b-'0')
+		}
+	}
+}
+
+func (d *Disassembler) consumeString(b byte) {
+	switch b {
+	case 7:
+		if d.cur.Kind == OSC {
+			d.cur.Raw = append(d.cur.Raw, b)
+			d.finish(OSC)
+			return
+		}
+	case 0x1b:
+		d.cur.Raw = append(d.cur.Raw, b)
+		d.state = stStringEsc
+		return
+	case 24, 26:
+		d.finish(Partial)
+		d.control(b)
+		return
+	}
+	if len(d.cur.Raw) >= maxRawLen {
+		d.cur.Dropped++
+		return
+	}
+	d.cur.Raw = append(d.cur.Raw, b)
+}
@@ -0,0 +1,216 @@
+package disasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greensnark/go-footv/compfile"
+	"github.com/greensnark/go-footv/ttyrec"
+)
+
+func disassemble(frames ...string) []*Element {
+	var res []*Element
+	d := New()
+	emit := func(e *Element) { res = append(res, e) }
+	for i, body := range frames {
+		d.Frame(i, time.Unix(int64(i), 0).UTC(), []byte(body), emit)
+	}
+	d.Flush(emit)
+	return res
+}
+
+type wantElement struct {
+	frame       int
+	token       string
+	describe    string
+	unsupported bool
+}
+
+func TestElements(t *testing.T) {
+	elements := disassemble(
+		"\033[4;20Hblah\033[1;31m\r\n\033[",
+		"?1049h\033]0;title\033\\\033]2;x\007\033(0q\033(Bé",
+		"\033[38;5;100;48;2;1;2;3m\033[>c\033Z\033[",
+	)
+	want := []wantElement{
+		{0, "CSI 4;20 H", "CUP row=4 col=20", false},
+		{0, `TEXT "blah"`, "", false},
+		{0, "CSI 1;31 m", "SGR bold fg=1", false},
+		{0, "CTRL CR", "", false},
+		{0, "CTRL LF", "", false},
+		{0, "CSI ?1049 h", "DECSET altscreen+save-cursor(1049)", true},
+		{1, `OSC "0;title"`, `set icon name and title="title"`, false},
+		{1, `OSC "2;x"`, `set title="x"`, false},
+		{1, "ESC (0", "SCS G0=DEC graphics", false},
+		{1, `TEXT "q"`, "", false},
+		{1, "ESC (B", "SCS G0=ASCII", false},
+		{1, `TEXT "é"`, "", false},
+		{2, "CSI 38;5;100;48;2;1;2;3 m", "SGR fg=100 bg=#010203", false},
+		{2, "CSI > c", "DA2", true},
+		{2, "ESC Z", "DECID", true},
+		{2, `PART "\x1b["`, "incomplete sequence", false},
+	}
+	if len(elements) != len(want) {
+		for _, e := range elements {
+			t.Log(e)
+		}
+		t.Fatalf("expected %d elements, got %d", len(want), len(elements))
+	}
+	for i, e := range elements {
+		w := want[i]
+		if e.Frame != w.frame || e.Token() != w.token ||
+			e.Describe() != w.describe || (len(e.Unsupported) > 0) != w.unsupported {
+			t.Errorf("element %d: expected %+v, got frame=%d %s / %s / %v",
+				i, w, e.Frame, e.Token(), e.Describe(), e.Unsupported)
+		}
+	}
+	if elements[5].Offset != 20 {
+		t.Errorf("expected split CSI at offset 20, got %d", elements[5].Offset)
+	}
+}
+
+func TestCSIBytes(t *testing.T) {
+	elements := disassemble(
+		"\033[38:5:100;48:2::1:2:3;4:3m\033[1\n2H\033[12\033[3\030" +
+			"\033[1?2h\033[1 2q\033[\xffm",
+	)
+	want := []wantElement{
+		{0, "CSI 38:5:100;48:2::1:2:3;4:3 m", "SGR fg=100 bg=#010203 ?4:3", true},
+		{0, "CTRL LF", "", false},
+		{0, "CSI 12 H", "CUP row=12 col=1", false},
+		{0, `PART "\x1b[12"`, "incomplete sequence", false},
+		{0, `PART "\x1b[3"`, "incomplete sequence", false},
+		{0, "CTRL CAN", "", false},
+		{0, `CSI "1?2h"`, "malformed CSI", true},
+		{0, `CSI "1 2q"`, "malformed CSI", false},
+		{0, `CSI "\xffm"`, "malformed CSI", true},
+	}
+	if len(elements) != len(want) {
+		for _, e := range elements {
+			t.Log(e)
+		}
+		t.Fatalf("expected %d elements, got %d", len(want), len(elements))
+	}
+	for i, e := range elements {
+		w := want[i]
+		if e.Token() != w.token || e.Describe() != w.describe ||
+			(len(e.Unsupported) > 0) != w.unsupported {
+			t.Errorf("element %d: expected %+v, got %s / %s / %v", i, w,
+				e.Token(), e.Describe(), e.Unsupported)
+		}
+	}
+}
+
+func TestLongSequences(t *testing.T) {
+	long := strings.Repeat(";", 2*maxRawLen)
+	seqs := []string{"\033[" + long + "m", "\033]0;" + long + "\007"}
+	elements := disassemble(strings.Join(seqs, ""))
+	if len(elements) != 2 {
+		t.Fatalf("expected 2 elements, got %d", len(elements))
+	}
+	for i, e := range elements {
+		if len(e.Raw) > maxRawLen+1 || e.Dropped == 0 {
+			t.Errorf("%s: expected a bounded Raw, got %d bytes, %d dropped",
+				e.Kind, len(e.Raw), e.Dropped)
+		}
+		if len(e.Raw)+e.Dropped != len(seqs[i]) {
+			t.Errorf("%s: %d bytes kept and %d dropped don't add up", e.Kind,
+				len(e.Raw), e.Dropped)
+		}
+	}
+	if elements[0].Final != 'm' || len(elements[0].Params) > maxRawLen {
+		t.Errorf("unexpected CSI: final %q, %d params", elements[0].Final,
+			len(elements[0].Params))
+	}
+	if raw := elements[1].Raw; raw[len(raw)-1] != 7 {
+		t.Errorf("OSC lost its terminator: %q", raw[len(raw)-8:])
+	}
+}
+
+func makeTtyrec(bodies ...string) *ttyrec.TReader {
+	buf := &bytes.Buffer{}
+	for i, body := range bodies {
+		hdr := make([]byte, ttyrec.HeaderSize)
+		binary.LittleEndian.PutUint32(hdr[0:4], uint32(1e9+i))
+		binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(body)))
+		buf.Write(hdr)
+		buf.WriteString(body)
+	}
+	return ttyrec.Reader(buf)
+}
+
+func TestDisassembleText(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Disassemble(makeTtyrec("\033[2Jhi", "\033[?1049h"), out, FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"2001-09-09T01:46:40.000000Z      0  CSI 2 J                   ED mode=2",
+		`2001-09-09T01:46:40.000000Z      0  TEXT "hi"`,
+		"2001-09-09T01:46:41.000000Z      1  CSI ?1049 h               DECSET altscreen+save-cursor(1049)  !! unsupported: CSI ? 1049 h",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got:\n%s", len(want), out)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: expected\n%s\ngot\n%s", i, want[i], lines[i])
+		}
+	}
+}
+
+func TestDisassembleJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Disassemble(makeTtyrec("a\033[5b"), out, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got:\n%s", out)
+	}
+	var e jsonElement
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Kind != CSI || string(e.Raw) != "\033[5b" || e.Offset != 1 ||
+		e.Describe != "REP n=5" || e.Token != "CSI 5 b" {
+		t.Errorf("unexpected JSON element: %s", lines[1])
+	}
+}
+
+func TestDisassembleJSONBinary(t *testing.T) {
+	raw := "\033]0;\xff\xfe\007"
+	out := &bytes.Buffer{}
+	if err := Disassemble(makeTtyrec(raw), out, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var e jsonElement
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &e); err != nil {
+		t.Fatal(err)
+	}
+	if string(e.Raw) != raw {
+		t.Errorf("expected raw %q, got %q", raw, e.Raw)
+	}
+}
+
+func TestDisassembleFile(t *testing.T) {
+	file, err := compfile.Open("../ttyrec/test/test.ttyrec.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	out := &bytes.Buffer{}
+	if err := Disassemble(ttyrec.Reader(file), out, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `TEXT "Player: trollherra"`) {
+		t.Errorf("expected the player name in the disassembly")
+	}
+}
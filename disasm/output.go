@@ -0,0 +1,110 @@
+package disasm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/greensnark/go-footv/ttyrec"
+)
+
+// Format selects how Disassemble prints elements.
+type Format int
+
+const (
+	// FormatText prints one aligned line per element.
+	FormatText Format = iota
+	// FormatJSON prints one JSON object per line per element.
+	FormatJSON
+)
+
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// String formats the element as a line of FormatText output, without
+// the trailing newline.
+func (e *Element) String() string {
+	line := fmt.Sprintf("%s %6d  %-24s", e.Time.Format(timeFormat), e.Frame, e.Token())
+	if desc := e.Describe(); desc != "" {
+		line += "  " + desc
+	}
+	if e.Dropped > 0 {
+		line += fmt.Sprintf("  (%d bytes dropped)", e.Dropped)
+	}
+	if len(e.Unsupported) > 0 {
+		line += "  !! unsupported: " + strings.Join(e.Unsupported, ", ")
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// jsonElement is an element in FormatJSON output. Raw is encoded in
+// base64, as it needn't be valid UTF-8.
+type jsonElement struct {
+	Time        time.Time `json:"time"`
+	Frame       int       `json:"frame"`
+	Offset      int64     `json:"offset"`
+	Kind        Kind      `json:"kind"`
+	Raw         []byte    `json:"raw"`
+	Dropped     int       `json:"dropped,omitempty"`
+	Token       string    `json:"token"`
+	Describe    string    `json:"describe,omitempty"`
+	Unsupported []string  `json:"unsupported,omitempty"`
+}
+
+// MarshalJSON encodes the element for FormatJSON output.
+func (e *Element) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonElement{
+		Time:        e.Time,
+		Frame:       e.Frame,
+		Offset:      e.Offset,
+		Kind:        e.Kind,
+		Raw:         e.Raw,
+		Dropped:     e.Dropped,
+		Token:       e.Token(),
+		Describe:    e.Describe(),
+		Unsupported: e.Unsupported,
+	})
+}
+
+// Disassemble reads every frame from r and writes a line describing
+// each element to w.
+func Disassemble(r *ttyrec.TReader, w io.Writer, format Format) error {
+	out := bufio.NewWriter(w)
+	var werr error
+	emit := func(e *Element) {
+		if werr != nil {
+			return
+		}
+		if format == FormatJSON {
+			var line []byte
+			if line, werr = e.MarshalJSON(); werr == nil {
+				out.Write(line)
+				werr = out.WriteByte('\n')
+			}
+			return
+		}
+		_, werr = fmt.Fprintln(out, e.String())
+	}
+
+	d := New()
+	for index := 0; ; index++ {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		d.Frame(index, frame.Time, frame.Body, emit)
+		if werr != nil {
+			return werr
+		}
+	}
+	d.Flush(emit)
+	if werr != nil {
+		return werr
+	}
+	return out.Flush()
+}
@@ -0,0 +1,147 @@
+package disasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of an Element.
+type Kind string
+
+const (
+	Text    Kind = "TEXT" // printable characters
+	Control Kind = "CTRL" // C0 control character
+	Esc     Kind = "ESC"  // escape sequence other than CSI and strings
+	CSI     Kind = "CSI"  // control sequence
+	OSC     Kind = "OSC"  // operating system command
+	String  Kind = "STR"  // DCS, APC, PM and SOS strings
+	Partial Kind = "PART" // sequence cut short by another one
+)
+
+// Element is a piece of terminal output: a run of text, a control
+// character or an escape sequence.
+type Element struct {
+	Time   time.Time
+	Frame  int   // index of the frame the element starts in
+	Offset int64 // offset of Raw in the stream of frame bodies
+	Kind   Kind
+	Raw    []byte
+	// Unsupported lists the diagnostics the terminal reported while
+	// handling the element; if there are any, it didn't play the
+	// element back faithfully.
+	Unsupported []string
+
+	// Dropped counts the bytes of an over-long sequence left out of
+	// Raw.
+	Dropped int
+
+	// Parsed CSI sequence. Private is the private marker, such as '?'.
+	// Sub holds the colon-separated sub-parameters that follow each
+	// parameter, as in 38:5:100, or is nil if there are none. Missing
+	// parameters are -1. Malformed is set if a byte is out of place, so
+	// that a terminal would ignore the sequence.
+	Private      byte
+	Params       []int
+	Sub          [][]int
+	Intermediate string
+	Final        byte
+	Malformed    bool
+}
+
+// Token returns the element in a readable but undecoded form, such
+// as "CSI 4;20 H" or `TEXT "blah"`.
+func (e *Element) Token() string {
+	switch e.Kind {
+	case Text:
+		return fmt.Sprintf("TEXT %q", e.Raw)
+	case Control:
+		return "CTRL " + controlName(e.Raw[0])
+	case CSI:
+		if e.Malformed {
+			return fmt.Sprintf("CSI %q", e.Raw[2:])
+		}
+		var b strings.Builder
+		b.WriteString("CSI ")
+		if e.Private != 0 {
+			b.WriteByte(e.Private)
+		}
+		b.WriteString(e.paramString())
+		if b.Len() > 4 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(e.Intermediate)
+		b.WriteByte(e.Final)
+		return b.String()
+	case Esc:
+		return "ESC " + string(e.Raw[1:])
+	case OSC, String:
+		return fmt.Sprintf("%s %q", e.Kind, e.payload())
+	default:
+		return fmt.Sprintf("%s %q", e.Kind, e.Raw)
+	}
+}
+
+func (e *Element) paramString() string {
+	if len(e.Params) == 1 && e.Params[0] < 0 && len(e.Sub) == 0 {
+		return ""
+	}
+	parts := make([]string, len(e.Params))
+	for i, p := range e.Params {
+		parts[i] = paramValue(p)
+		if i < len(e.Sub) {
+			for _, sub := range e.Sub[i] {
+				parts[i] += ":" + paramValue(sub)
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+func paramValue(p int) string {
+	if p < 0 {
+		return ""
+	}
+	return strconv.Itoa(p)
+}
+
+// payload returns the body of an OSC or other string, without the
+// introducer and terminator.
+func (e *Element) payload() []byte {
+	body := e.Raw[2:]
+	switch {
+	case len(body) > 0 && body[len(body)-1] == 7:
+		body = body[:len(body)-1]
+	case len(body) > 1 && body[len(body)-2] == 0x1b && body[len(body)-1] == '\\':
+		body = body[:len(body)-2]
+	case len(body) > 0 && body[len(body)-1] == 0x1b:
+		body = body[:len(body)-1]
+	}
+	return body
+}
+
+// param returns parameter i, or def if it is missing or zero.
+func (e *Element) param(i, def int) int {
+	if i < len(e.Params) && e.Params[i] > 0 {
+		return e.Params[i]
+	}
+	return def
+}
+
+var controlNames = [...]string{
+	"NUL", "SOH", "STX", "ETX", "EOT", "ENQ", "ACK", "BEL",
+	"BS", "HT", "LF", "VT", "FF", "CR", "SO", "SI",
+	"DLE", "DC1", "DC2", "DC3", "DC4", "NAK", "SYN", "ETB",
+	"CAN", "EM", "SUB", "ESC", "FS", "GS", "RS", "US",
+}
+
+func controlName(b byte) string {
+	if int(b) < len(controlNames) {
+		return controlNames[b]
+	}
+	if b == 0x7f {
+		return "DEL"
+	}
+	return fmt.Sprintf("0x%02x", b)
+}
@@ -0,0 +1,99 @@
+// Package player plays ttyrec recordings back onto a virtual
+// terminal, keeping track of where in the recording it is.
+package player
+
+import (
+	"io"
+	"time"
+
+	"github.com/greensnark/go-footv/ttyrec"
+	"github.com/greensnark/go-footv/vt"
+)
+
+// BellEvent records the BELs written by a single frame.
+type BellEvent struct {
+	time.Time
+	Frame int // index of the frame, from 0
+	Count int // number of BELs in the frame
+}
+
+// Player feeds ttyrec frames to a Tty.
+type Player struct {
+	Tty *vt.Tty
+	// Frame is the index of the last frame played, or -1 if none has
+	// been played yet.
+	Frame int
+	// Time is the timestamp of the last frame played.
+	Time time.Time
+	// Bells lists every frame that rang the bell, in order.
+	Bells []BellEvent
+
+	reader *ttyrec.TReader
+}
+
+// New returns a player that reads frames from r and writes them to
+// tty.
+func New(r *ttyrec.TReader, tty *vt.Tty) *Player {
+	return &Player{Tty: tty, Frame: -1, reader: r}
+}
+
+// Next reads the next frame and writes it to the Tty. The returned
+// frame remains valid only until the next call to Next. At the end of
+// the recording, Next returns io.EOF.
+func (p *Player) Next() (*ttyrec.Frame, error) {
+	frame, err := p.reader.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	p.Frame++
+	p.Time = frame.Time
+
+	bells := p.Tty.Bells
+	p.Tty.Frame = p.Frame
+	p.Tty.Write(frame.Body)
+	if rung := p.Tty.Bells - bells; rung > 0 {
+		p.Bells = append(p.Bells, BellEvent{
+			Time:  frame.Time,
+			Frame: p.Frame,
+			Count: rung,
+		})
+	}
+	return frame, nil
+}
+
+// PlayAll plays every remaining frame. Reaching the end of the
+// recording is not an error.
+func (p *Player) PlayAll() error {
+	for {
+		if _, err := p.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// BellBursts groups bell events into bursts, where each event follows
+// the previous one within gap. Only bursts with at least minBells BELs
+// are returned.
+func BellBursts(events []BellEvent, gap time.Duration, minBells int) [][]BellEvent {
+	var bursts [][]BellEvent
+	var burst []BellEvent
+	bells := 0
+	flush := func() {
+		if bells >= minBells && len(burst) > 0 {
+			bursts = append(bursts, burst)
+		}
+		burst, bells = nil, 0
+	}
+	for _, ev := range events {
+		if len(burst) > 0 && ev.Time.Sub(burst[len(burst)-1].Time) > gap {
+			flush()
+		}
+		burst = append(burst, ev)
+		bells += ev.Count
+	}
+	flush()
+	return bursts
+}
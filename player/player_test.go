@@ -0,0 +1,132 @@
+package player
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/greensnark/go-footv/compfile"
+	"github.com/greensnark/go-footv/ttyrec"
+	"github.com/greensnark/go-footv/vt"
+)
+
+type testFrame struct {
+	usec int
+	body string
+}
+
+func makeTtyrec(frames []testFrame) *ttyrec.TReader {
+	buf := &bytes.Buffer{}
+	for _, f := range frames {
+		hdr := make([]byte, ttyrec.HeaderSize)
+		binary.LittleEndian.PutUint32(hdr[0:4], uint32(1e6+f.usec/1e6))
+		binary.LittleEndian.PutUint32(hdr[4:8], uint32(f.usec%1e6))
+		binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(f.body)))
+		buf.Write(hdr)
+		buf.WriteString(f.body)
+	}
+	return ttyrec.Reader(buf)
+}
+
+func TestBells(t *testing.T) {
+	rec := makeTtyrec([]testFrame{
+		{0, "hello"},
+		{100, "\007beep\007"},
+		{200, "\033]0;title\007"},
+		{2e6, "again\007"},
+		{2e6 + 100, "and\007"},
+	})
+	tty := vt.New()
+	rung := 0
+	tty.Bell = func(*vt.Tty) { rung++ }
+	p := New(rec, tty)
+	if err := p.PlayAll(); err != nil {
+		t.Fatal(err)
+	}
+	if tty.Bells != 4 || rung != 4 {
+		t.Errorf("expected 4 bells, got Bells=%d, callbacks=%d", tty.Bells, rung)
+	}
+	if p.Frame != 4 {
+		t.Errorf("expected to end at frame 4, got %d", p.Frame)
+	}
+
+	want := []BellEvent{
+		{time.Unix(1e6, 100e3).UTC(), 1, 2},
+		{time.Unix(1e6+2, 0).UTC(), 3, 1},
+		{time.Unix(1e6+2, 100e3).UTC(), 4, 1},
+	}
+	if len(p.Bells) != len(want) {
+		t.Fatalf("expected bell events %v, got %v", want, p.Bells)
+	}
+	for i, ev := range p.Bells {
+		if !ev.Time.Equal(want[i].Time) || ev.Frame != want[i].Frame ||
+			ev.Count != want[i].Count {
+			t.Errorf("bell event %d: expected %v, got %v", i, want[i], ev)
+		}
+	}
+
+	bursts := BellBursts(p.Bells, time.Second, 2)
+	if len(bursts) != 2 || len(bursts[0]) != 1 || len(bursts[1]) != 2 {
+		t.Errorf("unexpected bursts: %v", bursts)
+	}
+	if bursts := BellBursts(p.Bells, time.Second, 3); len(bursts) != 0 {
+		t.Errorf("expected no bursts of 3, got %v", bursts)
+	}
+}
+
+func TestPlayFile(t *testing.T) {
+	file, err := compfile.Open("../ttyrec/test/test.ttyrec.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	p := New(ttyrec.Reader(file), vt.New())
+	if err := p.PlayAll(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Frame != 1205 {
+		t.Errorf("expected 1206 frames, last was %d", p.Frame)
+	}
+	if want := time.Unix(1425436272, 0); p.Time.Before(want) {
+		t.Errorf("last frame time %s is before the first frame", p.Time)
+	}
+}
+
+func TestDiagnosticFrames(t *testing.T) {
+	rec := makeTtyrec([]testFrame{
+		{0, "hello"},
+		{100, "\033[?1049h"},
+		{200, "world\033[4h"},
+	})
+	tty := vt.New()
+	counter := vt.NewDiagnosticCounter()
+	tty.Observer = counter
+	if err := New(rec, tty).PlayAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		name   string
+		frame  int
+		offset int64
+	}{
+		{"CSI ? 1049 h", 1, 5},
+		{"CSI 4 h", 2, 18},
+	} {
+		found := false
+		for _, count := range counter.Counts() {
+			d := count.First
+			if d.Name == want.name {
+				found = true
+				if d.Frame != want.frame || d.Offset != want.offset {
+					t.Errorf("%s: expected frame %d offset %d, got frame %d offset %d",
+						want.name, want.frame, want.offset, d.Frame, d.Offset)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no %s diagnostic", want.name)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package terminfo
+
+import "strings"
+
+// fallbacks are built-in entries for common terminals, for systems
+// without a terminfo database. They carry the capabilities needed to
+// draw a screen and read keys, not whole entries.
+var fallbacks = map[string]*Terminfo{
+	"ansi": {
+		Names:   []string{"ansi", "ansi/pc-term compatible with color"},
+		Bools:   map[string]bool{"am": true, "mir": true, "msgr": true, "AX": true},
+		Numbers: map[string]int{"cols": 80, "lines": 24, "colors": 8, "pairs": 64, "it": 8},
+		Strings: map[string]string{
+			"acsc":  "+\020,\021-\030.\0310\333`\004a\261f\370g\361h\260j\331k\277l\332m\300n\305o~p\304q\304r\304s_t\303u\264v\301w\302x\263y\363z\362{\343|\330}\234~\376",
+			"bel":   "\007",
+			"blink": "\033[5m",
+			"bold":  "\033[1m",
+			"clear": "\033[H\033[J",
+			"cr":    "\015",
+			"cub":   "\033[%p1%dD",
+			"cub1":  "\033[D",
+			"cud":   "\033[%p1%dB",
+			"cud1":  "\033[B",
+			"cuf":   "\033[%p1%dC",
+			"cuf1":  "\033[C",
+			"cup":   "\033[%i%p1%d;%p2%dH",
+			"cuu":   "\033[%p1%dA",
+			"cuu1":  "\033[A",
+			"dch":   "\033[%p1%dP",
+			"dch1":  "\033[P",
+			"dl":    "\033[%p1%dM",
+			"dl1":   "\033[M",
+			"ech":   "\033[%p1%dX",
+			"ed":    "\033[J",
+			"el":    "\033[K",
+			"el1":   "\033[1K",
+			"home":  "\033[H",
+			"hpa":   "\033[%i%p1%dG",
+			"ht":    "\033[I",
+			"ich":   "\033[%p1%d@",
+			"il":    "\033[%p1%dL",
+			"il1":   "\033[L",
+			"ind":   "\012",
+			"indn":  "\033[%p1%dS",
+			"invis": "\033[8m",
+			"kbs":   "\010",
+			"kcub1": "\033[D",
+			"kcud1": "\033[B",
+			"kcuf1": "\033[C",
+			"kcuu1": "\033[A",
+			"khome": "\033[H",
+			"kich1": "\033[L",
+			"op":    "\033[39;49m",
+			"rev":   "\033[7m",
+			"rin":   "\033[%p1%dT",
+			"rmacs": "\033[10m",
+			"rmso":  "\033[m",
+			"rmul":  "\033[m",
+			"setab": "\033[4%p1%dm",
+			"setaf": "\033[3%p1%dm",
+			"sgr":   "\033[0;10%?%p1%t;7%;%?%p2%t;4%;%?%p3%t;7%;%?%p4%t;5%;%?%p6%t;1%;%?%p7%t;8%;%?%p9%t;11%;m",
+			"sgr0":  "\033[0;10m",
+			"smacs": "\033[11m",
+			"smso":  "\033[7m",
+			"smul":  "\033[4m",
+			"vpa":   "\033[%i%p1%dd",
+		},
+	},
+	"linux": {
+		Names:   []string{"linux", "Linux console"},
+		Bools:   map[string]bool{"am": true, "bce": true, "ccc": true, "mir": true, "msgr": true, "xenl": true, "xon": true, "AX": true},
+		Numbers: map[string]int{"colors": 8, "pairs": 64, "it": 8, "U8": 1},
+		Strings: map[string]string{
+			"acsc":  "++,,--..00``aaffgghhiijjkkllmmnnooppqqrrssttuuvvwwxxyyzz{{||}}~~",
+			"bel":   "\007",
+			"blink": "\033[5m",
+			"bold":  "\033[1m",
+			"civis": "\033[?25l\033[?1c",
+			"clear": "\033[H\033[J",
+			"cnorm": "\033[?25h\033[?0c",
+			"cr":    "\015",
+			"csr":   "\033[%i%p1%d;%p2%dr",
+			"cub":   "\033[%p1%dD",
+			"cub1":  "\010",
+			"cud":   "\033[%p1%dB",
+			"cud1":  "\012",
+			"cuf":   "\033[%p1%dC",
+			"cuf1":  "\033[C",
+			"cup":   "\033[%i%p1%d;%p2%dH",
+			"cuu":   "\033[%p1%dA",
+			"cuu1":  "\033[A",
+			"dch":   "\033[%p1%dP",
+			"dch1":  "\033[P",
+			"dim":   "\033[2m",
+			"dl":    "\033[%p1%dM",
+			"dl1":   "\033[M",
+			"ech":   "\033[%p1%dX",
+			"ed":    "\033[J",
+			"el":    "\033[K",
+			"el1":   "\033[1K",
+			"enacs": "\033)0",
+			"home":  "\033[H",
+			"hpa":   "\033[%i%p1%dG",
+			"ht":    "\011",
+			"ich":   "\033[%p1%d@",
+			"il":    "\033[%p1%dL",
+			"il1":   "\033[L",
+			"ind":   "\012",
+			"kbs":   "\177",
+			"kcub1": "\033[D",
+			"kcud1": "\033[B",
+			"kcuf1": "\033[C",
+			"kcuu1": "\033[A",
+			"kdch1": "\033[3~",
+			"kend":  "\033[4~",
+			"kf1":   "\033[[A",
+			"kf10":  "\033[21~",
+			"kf11":  "\033[23~",
+			"kf12":  "\033[24~",
+			"kf2":   "\033[[B",
+			"kf3":   "\033[[C",
+			"kf4":   "\033[[D",
+			"kf5":   "\033[[E",
+			"kf6":   "\033[17~",
+			"kf7":   "\033[18~",
+			"kf8":   "\033[19~",
+			"kf9":   "\033[20~",
+			"khome": "\033[1~",
+			"kich1": "\033[2~",
+			"knp":   "\033[6~",
+			"kpp":   "\033[5~",
+			"op":    "\033[39;49m",
+			"rev":   "\033[7m",
+			"ri":    "\033M",
+			"rmacs": "\017",
+			"rmam":  "\033[?7l",
+			"rmso":  "\033[27m",
+			"rmul":  "\033[24m",
+			"setab": "\033[4%p1%dm",
+			"setaf": "\033[3%p1%dm",
+			"sgr":   "\033[0;10%?%p1%t;7%;%?%p2%t;4%;%?%p3%t;7%;%?%p4%t;5%;%?%p5%t;2%;%?%p6%t;1%;m%?%p9%t\016%e\017%;",
+			"sgr0":  "\033[m\017",
+			"smacs": "\016",
+			"smam":  "\033[?7h",
+			"smso":  "\033[7m",
+			"smul":  "\033[4m",
+			"vpa":   "\033[%i%p1%dd",
+		},
+	},
+	"screen": {
+		Names:   []string{"screen", "VT 100/ANSI X3.64 virtual terminal"},
+		Bools:   map[string]bool{"am": true, "km": true, "mir": true, "msgr": true, "xenl": true, "AX": true},
+		Numbers: map[string]int{"cols": 80, "lines": 24, "colors": 8, "pairs": 64, "it": 8, "U8": 1},
+		Strings: map[string]string{
+			"acsc":  "++,,--..00``aaffgghhiijjkkllmmnnooppqqrrssttuuvvwwxxyyzz{{||}}~~",
+			"bel":   "\007",
+			"blink": "\033[5m",
+			"bold":  "\033[1m",
+			"civis": "\033[?25l",
+			"clear": "\033[H\033[J",
+			"cnorm": "\033[34h\033[?25h",
+			"cr":    "\015",
+			"csr":   "\033[%i%p1%d;%p2%dr",
+			"cub":   "\033[%p1%dD",
+			"cub1":  "\010",
+			"cud":   "\033[%p1%dB",
+			"cud1":  "\012",
+			"cuf":   "\033[%p1%dC",
+			"cuf1":  "\033[C",
+			"cup":   "\033[%i%p1%d;%p2%dH",
+			"cuu":   "\033[%p1%dA",
+			"cuu1":  "\033M",
+			"dch":   "\033[%p1%dP",
+			"dch1":  "\033[P",
+			"dim":   "\033[2m",
+			"dl":    "\033[%p1%dM",
+			"dl1":   "\033[M",
+			"ed":    "\033[J",
+			"el":    "\033[K",
+			"el1":   "\033[1K",
+			"enacs": "\033(B\033)0",
+			"home":  "\033[H",
+			"hpa":   "\033[%i%p1%dG",
+			"ht":    "\011",
+			"ich":   "\033[%p1%d@",
+			"il":    "\033[%p1%dL",
+			"il1":   "\033[L",
+			"ind":   "\012",
+			"indn":  "\033[%p1%dS",
+			"kbs":   "\177",
+			"kcub1": "\033OD",
+			"kcud1": "\033OB",
+			"kcuf1": "\033OC",
+			"kcuu1": "\033OA",
+			"kdch1": "\033[3~",
+			"kend":  "\033[4~",
+			"kf1":   "\033OP",
+			"kf10":  "\033[21~",
+			"kf11":  "\033[23~",
+			"kf12":  "\033[24~",
+			"kf2":   "\033OQ",
+			"kf3":   "\033OR",
+			"kf4":   "\033OS",
+			"kf5":   "\033[15~",
+			"kf6":   "\033[17~",
+			"kf7":   "\033[18~",
+			"kf8":   "\033[19~",
+			"kf9":   "\033[20~",
+			"khome": "\033[1~",
+			"kich1": "\033[2~",
+			"knp":   "\033[6~",
+			"kpp":   "\033[5~",
+			"op":    "\033[39;49m",
+			"rev":   "\033[7m",
+			"ri":    "\033M",
+			"rin":   "\033[%p1%dT",
+			"rmacs": "\017",
+			"rmcup": "\033[?1049l",
+			"rmkx":  "\033[?1l\033>",
+			"rmso":  "\033[23m",
+			"rmul":  "\033[24m",
+			"setab": "\033[4%p1%dm",
+			"setaf": "\033[3%p1%dm",
+			"sgr":   "\033[0%?%p6%t;1%;%?%p1%t;3%;%?%p2%t;4%;%?%p3%t;7%;%?%p4%t;5%;%?%p5%t;2%;m%?%p9%t\016%e\017%;",
+			"sgr0":  "\033[m\017",
+			"smacs": "\016",
+			"smcup": "\033[?1049h",
+			"smkx":  "\033[?1h\033=",
+			"smso":  "\033[3m",
+			"smul":  "\033[4m",
+			"vpa":   "\033[%i%p1%dd",
+		},
+	},
+	"vt100": {
+		Names:   []string{"vt100", "vt100-am", "DEC VT100 (w/advanced video)"},
+		Bools:   map[string]bool{"am": true, "msgr": true, "xenl": true, "xon": true},
+		Numbers: map[string]int{"cols": 80, "lines": 24, "it": 8},
+		Strings: map[string]string{
+			"acsc":  "``aaffggjjkkllmmnnooppqqrrssttuuvvwwxxyyzz{{||}}~~",
+			"bel":   "\007",
+			"blink": "\033[5m$<2>",
+			"bold":  "\033[1m$<2>",
+			"clear": "\033[H\033[J$<50>",
+			"cr":    "\015",
+			"csr":   "\033[%i%p1%d;%p2%dr",
+			"cub":   "\033[%p1%dD",
+			"cub1":  "\010",
+			"cud":   "\033[%p1%dB",
+			"cud1":  "\012",
+			"cuf":   "\033[%p1%dC",
+			"cuf1":  "\033[C$<2>",
+			"cup":   "\033[%i%p1%d;%p2%dH$<5>",
+			"cuu":   "\033[%p1%dA",
+			"cuu1":  "\033[A$<2>",
+			"ed":    "\033[J$<50>",
+			"el":    "\033[K$<3>",
+			"el1":   "\033[1K$<3>",
+			"enacs": "\033(B\033)0",
+			"home":  "\033[H",
+			"ht":    "\011",
+			"ind":   "\012",
+			"kbs":   "\010",
+			"kcub1": "\033OD",
+			"kcud1": "\033OB",
+			"kcuf1": "\033OC",
+			"kcuu1": "\033OA",
+			"kf1":   "\033OP",
+			"kf10":  "\033Ox",
+			"kf2":   "\033OQ",
+			"kf3":   "\033OR",
+			"kf4":   "\033OS",
+			"kf5":   "\033Ot",
+			"kf6":   "\033Ou",
+			"kf7":   "\033Ov",
+			"kf8":   "\033Ol",
+			"kf9":   "\033Ow",
+			"rev":   "\033[7m$<2>",
+			"ri":    "\033M$<5>",
+			"rmacs": "\017",
+			"rmam":  "\033[?7l",
+			"rmkx":  "\033[?1l\033>",
+			"rmso":  "\033[m$<2>",
+			"rmul":  "\033[m$<2>",
+			"sgr":   "\033[0%?%p1%p6%|%t;1%;%?%p2%t;4%;%?%p1%p3%|%t;7%;%?%p4%t;5%;m%?%p9%t\016%e\017%;$<2>",
+			"sgr0":  "\033[m\017$<2>",
+			"smacs": "\016",
+			"smam":  "\033[?7h",
+			"smkx":  "\033[?1h\033=",
+			"smso":  "\033[7m$<2>",
+			"smul":  "\033[4m$<2>",
+		},
+	},
+	"xterm": {
+		Names:   []string{"xterm", "xterm-debian", "xterm terminal emulator (X Window System)"},
+		Bools:   map[string]bool{"am": true, "bce": true, "km": true, "mir": true, "msgr": true, "xenl": true, "AX": true},
+		Numbers: map[string]int{"cols": 80, "lines": 24, "colors": 8, "pairs": 64, "it": 8},
+		Strings: map[string]string{
+			"acsc":  "``aaffggiijjkkllmmnnooppqqrrssttuuvvwwxxyyzz{{||}}~~",
+			"bel":   "\007",
+			"blink": "\033[5m",
+			"bold":  "\033[1m",
+			"civis": "\033[?25l",
+			"clear": "\033[H\033[2J",
+			"cnorm": "\033[?12l\033[?25h",
+			"cr":    "\015",
+			"csr":   "\033[%i%p1%d;%p2%dr",
+			"cub":   "\033[%p1%dD",
+			"cub1":  "\010",
+			"cud":   "\033[%p1%dB",
+			"cud1":  "\012",
+			"cuf":   "\033[%p1%dC",
+			"cuf1":  "\033[C",
+			"cup":   "\033[%i%p1%d;%p2%dH",
+			"cuu":   "\033[%p1%dA",
+			"cuu1":  "\033[A",
+			"dch":   "\033[%p1%dP",
+			"dch1":  "\033[P",
+			"dim":   "\033[2m",
+			"dl":    "\033[%p1%dM",
+			"dl1":   "\033[M",
+			"ech":   "\033[%p1%dX",
+			"ed":    "\033[J",
+			"el":    "\033[K",
+			"el1":   "\033[1K",
+			"home":  "\033[H",
+			"hpa":   "\033[%i%p1%dG",
+			"ht":    "\011",
+			"ich":   "\033[%p1%d@",
+			"il":    "\033[%p1%dL",
+			"il1":   "\033[L",
+			"ind":   "\012",
+			"indn":  "\033[%p1%dS",
+			"invis": "\033[8m",
+			"kbs":   "\177",
+			"kcub1": "\033OD",
+			"kcud1": "\033OB",
+			"kcuf1": "\033OC",
+			"kcuu1": "\033OA",
+			"kdch1": "\033[3~",
+			"kend":  "\033OF",
+			"kf1":   "\033OP",
+			"kf10":  "\033[21~",
+			"kf11":  "\033[23~",
+			"kf12":  "\033[24~",
+			"kf2":   "\033OQ",
+			"kf3":   "\033OR",
+			"kf4":   "\033OS",
+			"kf5":   "\033[15~",
+			"kf6":   "\033[17~",
+			"kf7":   "\033[18~",
+			"kf8":   "\033[19~",
+			"kf9":   "\033[20~",
+			"khome": "\033OH",
+			"kich1": "\033[2~",
+			"knp":   "\033[6~",
+			"kpp":   "\033[5~",
+			"op":    "\033[39;49m",
+			"rev":   "\033[7m",
+			"ri":    "\033M",
+			"rin":   "\033[%p1%dT",
+			"ritm":  "\033[23m",
+			"rmacs": "\033(B",
+			"rmam":  "\033[?7l",
+			"rmcup": "\033[?1049l\033[23;0;0t",
+			"rmkx":  "\033[?1l\033>",
+			"rmso":  "\033[27m",
+			"rmul":  "\033[24m",
+			"setab": "\033[4%p1%dm",
+			"setaf": "\033[3%p1%dm",
+			"sgr":   "%?%p9%t\033(0%e\033(B%;\033[0%?%p6%t;1%;%?%p5%t;2%;%?%p2%t;4%;%?%p1%p3%|%t;7%;%?%p4%t;5%;%?%p7%t;8%;m",
+			"sgr0":  "\033(B\033[m",
+			"sitm":  "\033[3m",
+			"smacs": "\033(0",
+			"smam":  "\033[?7h",
+			"smcup": "\033[?1049h\033[22;0;0t",
+			"smkx":  "\033[?1h\033=",
+			"smso":  "\033[7m",
+			"smul":  "\033[4m",
+			"vpa":   "\033[%i%p1%dd",
+		},
+	},
+}
+
+// Fallback returns a built-in entry for term, which may be ansi, linux,
+// screen, vt100 or xterm, or a variant of one such as xterm-color. The
+// entry for a variant is its base terminal's.
+func Fallback(term string) (*Terminfo, bool) {
+	for {
+		if ti, ok := fallbacks[term]; ok {
+			return ti.clone(), true
+		}
+		i := strings.LastIndexByte(term, '-')
+		if i < 0 {
+			return nil, false
+		}
+		term = term[:i]
+	}
+}
+
+// clone copies ti, so that callers can change the maps of a fallback.
+func (ti *Terminfo) clone() *Terminfo {
+	c := &Terminfo{
+		Names:   append([]string(nil), ti.Names...),
+		Bools:   map[string]bool{},
+		Numbers: map[string]int{},
+		Strings: map[string]string{},
+	}
+	for k, v := range ti.Bools {
+		c.Bools[k] = v
+	}
+	for k, v := range ti.Numbers {
+		c.Numbers[k] = v
+	}
+	for k, v := range ti.Strings {
+		c.Strings[k] = v
+	}
+	return c
+}
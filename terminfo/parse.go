@@ -0,0 +1,230 @@
+package terminfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Magic numbers of the legacy format, with 16-bit numbers, and the
+// extended number format of ncurses 6.1, with 32-bit numbers.
+const (
+	magicLegacy = 0432
+	magic32     = 01036
+)
+
+var errTruncated = errors.New("terminfo: truncated entry")
+
+// decoder reads the little-endian fields of a compiled entry.
+type decoder struct {
+	data     []byte
+	pos      int
+	numWidth int
+	err      error
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data)-d.pos {
+		d.err = errTruncated
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+// short reads a signed 16-bit value.
+func (d *decoder) short() int {
+	b := d.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(int16(binary.LittleEndian.Uint16(b)))
+}
+
+// number reads a numeric capability, 16 or 32 bits wide.
+func (d *decoder) number() int {
+	if d.numWidth == 2 {
+		return d.short()
+	}
+	b := d.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int(int32(binary.LittleEndian.Uint32(b)))
+}
+
+// align skips the padding byte that brings the offset to an even
+// boundary.
+func (d *decoder) align() {
+	if d.pos%2 == 1 {
+		d.bytes(1)
+	}
+}
+
+// Absent and cancelled values are stored as -1 and -2.
+const (
+	absent    = -1
+	cancelled = -2
+)
+
+// Parse decodes a compiled terminfo entry, in the legacy or extended
+// number format, including any extended capabilities.
+func Parse(data []byte) (*Terminfo, error) {
+	d := &decoder{data: data, numWidth: 2}
+	switch d.short() {
+	case magicLegacy:
+	case magic32:
+		d.numWidth = 4
+	default:
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, errors.New("terminfo: bad magic number")
+	}
+	namesSize, nBools, nNums, nStrings, tableSize :=
+		d.short(), d.short(), d.short(), d.short(), d.short()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if namesSize < 0 || nBools < 0 || nNums < 0 || nStrings < 0 || tableSize < 0 {
+		return nil, fmt.Errorf("terminfo: negative header field (%d, %d, %d, %d, %d)",
+			namesSize, nBools, nNums, nStrings, tableSize)
+	}
+	if nBools > len(boolNames) || nNums > len(numNames) ||
+		nStrings > len(stringNames) {
+		return nil, fmt.Errorf("terminfo: too many capabilities (%d, %d, %d)",
+			nBools, nNums, nStrings)
+	}
+
+	ti := &Terminfo{
+		Bools:   map[string]bool{},
+		Numbers: map[string]int{},
+		Strings: map[string]string{},
+	}
+	names := string(bytes.TrimRight(d.bytes(namesSize), "\x00"))
+	ti.Names = strings.Split(names, "|")
+
+	bools := d.bytes(nBools)
+	d.align()
+	nums := d.numbers(nNums)
+	offsets := d.shorts(nStrings)
+	table := d.bytes(tableSize)
+	if d.err != nil {
+		return nil, d.err
+	}
+	for i, b := range bools {
+		if b == 1 {
+			ti.Bools[boolNames[i]] = true
+		}
+	}
+	for i, n := range nums {
+		if n >= 0 {
+			ti.Numbers[numNames[i]] = n
+		}
+	}
+	for i, off := range offsets {
+		if s, ok := cString(table, off); ok {
+			ti.Strings[stringNames[i]] = s
+		}
+	}
+
+	d.align()
+	if d.pos >= len(d.data) {
+		return ti, nil
+	}
+	if err := d.extended(ti); err != nil {
+		return nil, err
+	}
+	return ti, nil
+}
+
+func (d *decoder) numbers(n int) []int {
+	nums := make([]int, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		nums = append(nums, d.number())
+	}
+	return nums
+}
+
+func (d *decoder) shorts(n int) []int {
+	shorts := make([]int, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		shorts = append(shorts, d.short())
+	}
+	return shorts
+}
+
+// cString returns the NUL-terminated string at offset off of table, if
+// off isn't an absent or cancelled marker.
+func cString(table []byte, off int) (string, bool) {
+	if off < 0 || off >= len(table) {
+		return "", false
+	}
+	s := table[off:]
+	if end := bytes.IndexByte(s, 0); end >= 0 {
+		s = s[:end]
+	}
+	return string(s), true
+}
+
+// extended decodes the extended capabilities section, whose string
+// table holds the string values followed by the capability names.
+func (d *decoder) extended(ti *Terminfo) error {
+	nBools, nNums, nStrings, nOffsets, tableSize :=
+		d.short(), d.short(), d.short(), d.short(), d.short()
+	if d.err != nil {
+		return d.err
+	}
+	if nBools < 0 || nNums < 0 || nStrings < 0 || tableSize < 0 ||
+		nOffsets != nStrings+nBools+nNums+nStrings {
+		return errors.New("terminfo: bad extended capability counts")
+	}
+	bools := d.bytes(nBools)
+	d.align()
+	nums := d.numbers(nNums)
+	values := d.shorts(nStrings)
+	nameOffsets := d.shorts(nBools + nNums + nStrings)
+	table := d.bytes(tableSize)
+	if d.err != nil {
+		return d.err
+	}
+
+	// The names start after the last string value.
+	namesAt := 0
+	for _, off := range values {
+		if s, ok := cString(table, off); ok && off+len(s)+1 > namesAt {
+			namesAt = off + len(s) + 1
+		}
+	}
+	names := make([]string, len(nameOffsets))
+	for i, off := range nameOffsets {
+		name, ok := cString(table, namesAt+off)
+		if off < 0 || !ok || name == "" {
+			return errors.New("terminfo: bad extended capability name")
+		}
+		names[i] = name
+	}
+
+	for i, b := range bools {
+		if b == 1 {
+			ti.Bools[names[i]] = true
+		}
+	}
+	for i, n := range nums {
+		if n >= 0 {
+			ti.Numbers[names[nBools+i]] = n
+		}
+	}
+	for i, off := range values {
+		if s, ok := cString(table, off); ok {
+			ti.Strings[names[nBools+nNums+i]] = s
+		}
+	}
+	return nil
+}
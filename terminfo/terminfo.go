@@ -0,0 +1,130 @@
+// Package terminfo reads compiled terminfo entries, to find out what a
+// viewer's terminal supports, and evaluates their parameterized
+// strings.
+package terminfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Terminfo is a terminal's entry: its names and capabilities, including
+// extended (user-defined) capabilities. Cancelled capabilities are
+// absent.
+type Terminfo struct {
+	// Names are the terminal's names, the last usually a description.
+	Names []string
+
+	Bools   map[string]bool
+	Numbers map[string]int
+	Strings map[string]string
+}
+
+// Name returns the terminal's primary name.
+func (ti *Terminfo) Name() string {
+	if len(ti.Names) == 0 {
+		return ""
+	}
+	return ti.Names[0]
+}
+
+// Bool reports whether the boolean capability name is set.
+func (ti *Terminfo) Bool(name string) bool {
+	return ti.Bools[name]
+}
+
+// Number returns the numeric capability name, or -1 if it is absent.
+func (ti *Terminfo) Number(name string) int {
+	if n, ok := ti.Numbers[name]; ok {
+		return n
+	}
+	return -1
+}
+
+// String returns the string capability name, and whether it is
+// present.
+func (ti *Terminfo) String(name string) (string, bool) {
+	s, ok := ti.Strings[name]
+	return s, ok
+}
+
+// Tparm returns the string capability name with params substituted, or
+// "" if it is absent.
+func (ti *Terminfo) Tparm(name string, params ...interface{}) string {
+	s, ok := ti.Strings[name]
+	if !ok {
+		return ""
+	}
+	return Tparm(s, params...)
+}
+
+var ErrNotFound = errors.New("terminfo: entry not found")
+
+// Dirs returns the directories searched for entries, in order: those
+// named by $TERMINFO, ~/.terminfo and $TERMINFO_DIRS, then the usual
+// system directories.
+func Dirs() []string {
+	var dirs []string
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	for _, dir := range strings.Split(os.Getenv("TERMINFO_DIRS"), ":") {
+		if dir == "" {
+			// An empty entry stands for the system directory.
+			dir = "/usr/share/terminfo"
+		}
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, "/etc/terminfo", "/lib/terminfo",
+		"/usr/share/terminfo", "/usr/lib/terminfo")
+}
+
+// Load reads the entry for term from the standard directories. If there
+// is none, it returns a built-in fallback; see Fallback.
+func Load(term string) (*Terminfo, error) {
+	ti, err := LoadFrom(Dirs(), term)
+	if err == ErrNotFound {
+		if fallback, ok := Fallback(term); ok {
+			return fallback, nil
+		}
+	}
+	return ti, err
+}
+
+// LoadFrom reads the entry for term from the first of dirs that has
+// it. Entries may be filed under their first letter, or under its hex
+// code as on macOS.
+func LoadFrom(dirs []string, term string) (*Terminfo, error) {
+	if term == "" || strings.ContainsAny(term, "/\x00") || term[0] == '.' {
+		return nil, ErrNotFound
+	}
+	for _, dir := range dirs {
+		for _, sub := range []string{term[:1], fmt.Sprintf("%02x", term[0])} {
+			ti, err := LoadFile(filepath.Join(dir, sub, term))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return ti, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// LoadFile reads the compiled entry at path.
+func LoadFile(path string) (*Terminfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ti, nil
+}
@@ -0,0 +1,151 @@
+package terminfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, want := range []struct {
+		file    string
+		name    string
+		bools   []string
+		numbers map[string]int
+		strings map[string]string
+	}{
+		{
+			file:    "l/linux", // legacy format, with extended capabilities
+			name:    "linux",
+			bools:   []string{"am", "bce", "xenl", "AX"},
+			numbers: map[string]int{"colors": 8, "pairs": 64, "U8": 1, "cols": -1},
+			strings: map[string]string{
+				"cup":  "\033[%i%p1%d;%p2%dH",
+				"kbs":  "\177",
+				"sgr0": "\033[m\017",
+			},
+		},
+		{
+			file:    "v/vt100", // legacy format
+			name:    "vt100",
+			bools:   []string{"am", "xenl", "OTbs"},
+			numbers: map[string]int{"cols": 80, "lines": 24, "colors": -1},
+			strings: map[string]string{
+				"bold":  "\033[1m$<2>",
+				"smacs": "\016",
+			},
+		},
+		{
+			file:    "x/xterm-256color", // extended number format
+			name:    "xterm-256color",
+			bools:   []string{"bce", "km", "XT"},
+			numbers: map[string]int{"colors": 256, "pairs": 65536},
+			strings: map[string]string{
+				"sitm": "\033[3m",
+				"E3":   "\033[3J",
+				"Ss":   "\033[%p1%d q",
+			},
+		},
+		{
+			file:    "x/xterm-direct",
+			name:    "xterm-direct",
+			bools:   []string{"RGB"},
+			numbers: map[string]int{"colors": 1 << 24, "CO": 8},
+		},
+	} {
+		ti, err := LoadFile(filepath.Join("test", want.file))
+		if err != nil {
+			t.Errorf("%s: %v", want.file, err)
+			continue
+		}
+		if ti.Name() != want.name {
+			t.Errorf("%s: expected name %q, got %q", want.file, want.name,
+				ti.Name())
+		}
+		for _, b := range want.bools {
+			if !ti.Bool(b) {
+				t.Errorf("%s: expected %s", want.file, b)
+			}
+		}
+		for name, n := range want.numbers {
+			if got := ti.Number(name); got != n {
+				t.Errorf("%s: expected %s#%d, got %d", want.file, name, n, got)
+			}
+		}
+		for name, s := range want.strings {
+			if got, _ := ti.String(name); got != s {
+				t.Errorf("%s: expected %s=%q, got %q", want.file, name, s, got)
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	data, err := os.ReadFile("test/x/xterm-256color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{
+		nil,
+		{0x1a},
+		{0x1a, 0x02, 0, 0},
+		// A negative number count.
+		{0x1a, 0x01, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 0},
+		data[:100],
+		data[:len(data)-1],
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("no error parsing %d bytes", len(bad))
+		}
+	}
+}
+
+func TestLoadFrom(t *testing.T) {
+	dirs := []string{"no-such-dir", "test"}
+	ti, err := LoadFrom(dirs, "vt100")
+	if err != nil || ti.Name() != "vt100" {
+		t.Errorf("expected vt100, got %v, %v", ti, err)
+	}
+	for _, term := range []string{"xterm-kitty", "", "../x/xterm-direct"} {
+		if _, err := LoadFrom(dirs, term); err != ErrNotFound {
+			t.Errorf("%q: expected ErrNotFound, got %v", term, err)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	for _, want := range []struct {
+		term, name string
+	}{
+		{"xterm", "xterm"},
+		{"xterm-kitty", "xterm"},
+		{"screen.xterm-256color", ""},
+		{"screen-256color-bce", "screen"},
+		{"linux", "linux"},
+		{"ansi", "ansi"},
+		{"vt100", "vt100"},
+		{"dumb", ""},
+	} {
+		ti, ok := Fallback(want.term)
+		if !ok {
+			if want.name != "" {
+				t.Errorf("%s: no fallback", want.term)
+			}
+			continue
+		}
+		if ti.Name() != want.name {
+			t.Errorf("%s: expected %s, got %s", want.term, want.name, ti.Name())
+		}
+		// vt100's cup ends in padding, $<5>.
+		if cup := ti.Tparm("cup", 4, 9); !strings.HasPrefix(cup, "\033[5;10H") {
+			t.Errorf("%s: cup: got %q", want.term, cup)
+		}
+	}
+
+	ti, _ := Fallback("xterm")
+	ti.Strings["cup"] = ""
+	if ti, _ := Fallback("xterm"); ti.Strings["cup"] == "" {
+		t.Errorf("changing a fallback changed the built-in entry")
+	}
+}
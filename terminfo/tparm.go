@@ -0,0 +1,299 @@
+package terminfo
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// value is a tparm stack entry or parameter: a number or a string.
+type value struct {
+	n     int
+	s     string
+	isStr bool
+}
+
+func (v value) str() string {
+	if v.isStr {
+		return v.s
+	}
+	return strconv.Itoa(v.n)
+}
+
+func toValue(p interface{}) value {
+	switch p := p.(type) {
+	case int:
+		return value{n: p}
+	case rune:
+		return value{n: int(p)}
+	case string:
+		return value{s: p, isStr: true}
+	case bool:
+		if p {
+			return value{n: 1}
+		}
+	}
+	return value{}
+}
+
+// tparm holds the state of one evaluation.
+type tparm struct {
+	s       string
+	pos     int
+	out     bytes.Buffer
+	stack   []value
+	params  [9]value
+	popped  bool // parameters are taken from the stack
+	dynamic [26]value
+	static  [26]value
+}
+
+func (t *tparm) push(v value)  { t.stack = append(t.stack, v) }
+func (t *tparm) pushInt(n int) { t.push(value{n: n}) }
+
+func (t *tparm) pushBool(b bool) {
+	if b {
+		t.pushInt(1)
+	} else {
+		t.pushInt(0)
+	}
+}
+
+// pop pops a value, or returns zero if the stack is empty.
+func (t *tparm) pop() value {
+	if len(t.stack) == 0 {
+		return value{}
+	}
+	v := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	return v
+}
+
+func (t *tparm) next() byte {
+	if t.pos >= len(t.s) {
+		return 0
+	}
+	c := t.s[t.pos]
+	t.pos++
+	return c
+}
+
+// Tparm evaluates the parameterized string s, as tparm(3) does. The
+// parameters are ints, runes, bools or strings; there may be up to
+// nine. Strings that never use %p take their parameters from the
+// stack, in order, as termcap strings do, and %i increments the next
+// two. Static variables (%PA-%PZ) do not persist between calls.
+func Tparm(s string, params ...interface{}) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	t := &tparm{s: s}
+	for i, p := range params {
+		if i < len(t.params) {
+			t.params[i] = toValue(p)
+		}
+	}
+	if !strings.Contains(s, "%p") {
+		t.popped = true
+		for i := len(params) - 1; i >= 0; i-- {
+			t.push(toValue(params[i]))
+		}
+	}
+	t.run()
+	return t.out.String()
+}
+
+func (t *tparm) run() {
+	for t.pos < len(t.s) {
+		c := t.next()
+		if c != '%' {
+			t.out.WriteByte(c)
+			continue
+		}
+		switch op := t.next(); op {
+		case '%':
+			t.out.WriteByte('%')
+		case 'c':
+			b := byte(t.pop().n)
+			if b == 0 {
+				// NUL would end a C string; tparm sends 0200 instead.
+				b = 0200
+			}
+			t.out.WriteByte(b)
+		case 's':
+			t.out.WriteString(t.pop().str())
+		case 'd', 'o', 'x', 'X', ':', '#', ' ', '.',
+			'0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			t.pos--
+			t.format()
+		case 'p':
+			if i := int(t.next() - '1'); i >= 0 && i < len(t.params) {
+				t.push(t.params[i])
+			}
+		case 'P':
+			if v := t.variable(t.next()); v != nil {
+				*v = t.pop()
+			}
+		case 'g':
+			if v := t.variable(t.next()); v != nil {
+				t.push(*v)
+			}
+		case '\'':
+			t.pushInt(int(t.next()))
+			t.next() // the closing quote
+		case '{':
+			n := 0
+			for c := t.next(); c >= '0' && c <= '9'; c = t.next() {
+				n = n*10 + int(c-'0')
+			}
+			t.pushInt(n)
+		case 'l':
+			t.pushInt(len(t.pop().str()))
+		case '+', '-', '*', '/', 'm', '&', '|', '^', '=', '<', '>', 'A', 'O':
+			b, a := t.pop().n, t.pop().n
+			t.binary(op, a, b)
+		case '!':
+			t.pushBool(t.pop().n == 0)
+		case '~':
+			t.pushInt(^t.pop().n)
+		case 'i':
+			t.params[0].n++
+			t.params[1].n++
+			if t.popped {
+				for i := len(t.stack) - 1; i >= 0 && i >= len(t.stack)-2; i-- {
+					t.stack[i].n++
+				}
+			}
+		case 't':
+			if t.pop().n == 0 {
+				t.skip(true)
+			}
+		case 'e':
+			t.skip(false)
+		case '?', ';':
+		}
+	}
+}
+
+func (t *tparm) binary(op byte, a, b int) {
+	switch op {
+	case '+':
+		t.pushInt(a + b)
+	case '-':
+		t.pushInt(a - b)
+	case '*':
+		t.pushInt(a * b)
+	case '/':
+		if b == 0 {
+			t.pushInt(0)
+		} else {
+			t.pushInt(a / b)
+		}
+	case 'm':
+		if b == 0 {
+			t.pushInt(0)
+		} else {
+			t.pushInt(a % b)
+		}
+	case '&':
+		t.pushInt(a & b)
+	case '|':
+		t.pushInt(a | b)
+	case '^':
+		t.pushInt(a ^ b)
+	case '=':
+		t.pushBool(a == b)
+	case '<':
+		t.pushBool(a < b)
+	case '>':
+		t.pushBool(a > b)
+	case 'A':
+		t.pushBool(a != 0 && b != 0)
+	case 'O':
+		t.pushBool(a != 0 || b != 0)
+	}
+}
+
+// variable returns the dynamic (a-z) or static (A-Z) variable named c.
+func (t *tparm) variable(c byte) *value {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return &t.dynamic[c-'a']
+	case c >= 'A' && c <= 'Z':
+		return &t.static[c-'A']
+	}
+	return nil
+}
+
+// format handles %[[:]flags][width[.precision]][doxXs], which is a
+// printf conversion. The colon keeps a - or + flag from reading as an
+// operator.
+func (t *tparm) format() {
+	spec := []byte{'%'}
+	if t.pos < len(t.s) && t.s[t.pos] == ':' {
+		t.pos++
+	}
+	for t.pos < len(t.s) && strings.IndexByte("-+# ", t.s[t.pos]) >= 0 {
+		spec = append(spec, t.next())
+	}
+	for t.pos < len(t.s) && t.s[t.pos] == '0' {
+		spec = append(spec, t.next())
+	}
+	if width, ok := t.number(); ok {
+		spec = strconv.AppendInt(spec, int64(width), 10)
+	}
+	if t.pos < len(t.s) && t.s[t.pos] == '.' {
+		t.pos++
+		precision, _ := t.number()
+		spec = strconv.AppendInt(append(spec, '.'), int64(precision), 10)
+	}
+	switch verb := t.next(); verb {
+	case 'd', 'o', 'x', 'X':
+		t.out.WriteString(fmt.Sprintf(string(append(spec, verb)), t.pop().n))
+	case 's':
+		t.out.WriteString(fmt.Sprintf(string(append(spec, verb)), t.pop().str()))
+	}
+}
+
+// maxFormatWidth caps the width and precision of a printf conversion,
+// so that a corrupt string can't ask for a gigabyte of padding.
+const maxFormatWidth = 256
+
+// number reads a decimal number of at most maxFormatWidth, for format.
+// ok is false if there are no digits.
+func (t *tparm) number() (n int, ok bool) {
+	for t.pos < len(t.s) && t.s[t.pos] >= '0' && t.s[t.pos] <= '9' {
+		n = n*10 + int(t.next()-'0')
+		if n > maxFormatWidth {
+			n = maxFormatWidth
+		}
+		ok = true
+	}
+	return n, ok
+}
+
+// skip skips the rest of a %t branch that is not taken, to just after
+// its %e, or if else is false or there is no %e, to after the %; that
+// ends the conditional.
+func (t *tparm) skip(toElse bool) {
+	level := 0
+	for t.pos < len(t.s) {
+		if t.next() != '%' {
+			continue
+		}
+		switch t.next() {
+		case '?':
+			level++
+		case ';':
+			if level == 0 {
+				return
+			}
+			level--
+		case 'e':
+			if level == 0 && toElse {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package terminfo
+
+import "testing"
+
+const (
+	setaf256 = "\033[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m"
+	setafRGB = "\033[%?%p1%{8}%<%t3%p1%d%e38:2::%p1%{65536}%/%d:" +
+		"%p1%{256}%/%{255}%&%d:%p1%{255}%&%d%;m"
+	sgr = "%?%p9%t\033(0%e\033(B%;\033[0%?%p6%t;1%;%?%p5%t;2%;%?%p2%t;4%;" +
+		"%?%p1%p3%|%t;7%;%?%p4%t;5%;%?%p7%t;8%;m"
+)
+
+func TestTparm(t *testing.T) {
+	for _, want := range []struct {
+		s      string
+		params []interface{}
+		out    string
+	}{
+		{"\033[H", nil, "\033[H"},
+		{"100%%", nil, "100%"},
+		{"\033[%i%p1%d;%p2%dH", []interface{}{0, 0}, "\033[1;1H"},
+		{"\033[%i%d;%dH", []interface{}{4, 9}, "\033[5;10H"},
+		{setaf256, []interface{}{3}, "\033[33m"},
+		{setaf256, []interface{}{12}, "\033[94m"},
+		{setaf256, []interface{}{208}, "\033[38;5;208m"},
+		{setafRGB, []interface{}{0xff8000}, "\033[38:2::255:128:0m"},
+		{sgr, []interface{}{0, 1, 0, 0, 0, 1, 0, 0, 0}, "\033(B\033[0;1;4m"},
+		{sgr, []interface{}{1, 0, 0, 1, 0, 0, 0, 0, true}, "\033(0\033[0;7;5m"},
+		{"%p1%c%p2%c", []interface{}{'A', 0}, "A\200"},
+		{"%p1%s=%p1%l%d", []interface{}{"abc"}, "abc=3"},
+		{"%p1%03d|%p1%:-4x|%p1%#o|%p2%5s", []interface{}{10, "ab"},
+			"010|a   |012|   ab"},
+		{"%p1%.3d|%p1%0.0d|%p1%2.1s", []interface{}{7}, "007|7| 7"},
+		{"%'A'%{2}%+%c", nil, "C"},
+		{"%{7}%{2}%m%d %{7}%{0}%/%d %{6}%{3}%^%d %p1%~%d", []interface{}{0},
+			"1 0 5 -1"},
+		{"%p1%Pa%p2%PZ%gZ%ga%-%d", []interface{}{3, 10}, "7"},
+		{"%?%p1%{1}%>%t%?%p2%tA%eB%;%eC%;", []interface{}{2, 0}, "B"},
+		{"%?%p1%{1}%>%t%?%p2%tA%eB%;%eC%;", []interface{}{1, 1}, "C"},
+		{"%?%p1%p2%A%tboth%;%?%p1%p2%O%!%tneither%;", []interface{}{1, 0}, ""},
+		{"%?%p1%{1}%=%t1%e%p1%{2}%=%t2%e3%;", []interface{}{2}, "2"},
+	} {
+		if out := Tparm(want.s, want.params...); out != want.out {
+			t.Errorf("%q %v: expected %q, got %q", want.s, want.params,
+				want.out, out)
+		}
+	}
+}
+
+func TestTparmHugeWidth(t *testing.T) {
+	for _, s := range []string{
+		"%p1%999999999d", "%p1%.999999999d", "%p1%99999999999999999999999s",
+	} {
+		if out := Tparm(s, 1); len(out) != maxFormatWidth {
+			t.Errorf("%q: expected %d bytes, got %d", s, maxFormatWidth, len(out))
+		}
+	}
+}
@@ -64,7 +64,7 @@ func (t *TReader) frameBufSize(min uint32) uint32 {
 
 func (t *TReader) readFrameBody(hdr header) (*Frame, error) {
 	if hdr.length > MaxFrameSizeBytes {
-		return nil, fmt.Errorf("ttyrec frame too large: %u", hdr.length)
+		return nil, fmt.Errorf("ttyrec frame too large: %d", hdr.length)
 	}
 	if int(hdr.length) > cap(t.framebuf) {
 		t.framebuf = make([]byte, hdr.length, t.frameBufSize(hdr.length))
@@ -1,6 +1,8 @@
 package ttyrec
 
 import (
+	"bytes"
+	"os"
 	"testing"
 	"time"
 
@@ -55,3 +57,35 @@ func TestReadTtyrec(t *testing.T) {
 		}
 	}
 }
+
+// FuzzTReader checks that reading arbitrary data never panics, and
+// that frames come from the data rather than from a previous frame.
+func FuzzTReader(f *testing.F) {
+	seed, err := os.ReadFile("test/test.ttyrec")
+	if err != nil {
+		f.Fatal(err)
+	}
+	// The first two frames; the whole file is too slow to mutate.
+	seed = seed[:2*HeaderSize+testFrames[0].Size+testFrames[1].Size]
+	f.Add(seed)
+	f.Add(seed[:HeaderSize+1])
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ttr := Reader(bytes.NewReader(data))
+		read := 0
+		for {
+			frame, err := ttr.ReadFrame()
+			if err != nil {
+				break
+			}
+			read += HeaderSize + len(frame.Body)
+			if read > len(data) {
+				t.Fatalf("read %d bytes of frames from %d bytes", read, len(data))
+			}
+			if !bytes.Equal(frame.Body, data[read-len(frame.Body):read]) {
+				t.Fatalf("frame ending at %d doesn't match the data", read)
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package unicode
+
+// wideRanges are the East Asian wide and fullwidth ranges, after Markus
+// Kuhn's wcwidth, plus the common emoji blocks.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f}, // Hangul Jamo initial consonants
+	{0x2329, 0x232a}, // angle brackets
+	{0x2e80, 0x303e}, // CJK radicals to CJK symbols and punctuation
+	{0x3040, 0xa4cf}, // Hiragana to Yi
+	{0xac00, 0xd7a3}, // Hangul syllables
+	{0xf900, 0xfaff}, // CJK compatibility ideographs
+	{0xfe10, 0xfe19}, // vertical forms
+	{0xfe30, 0xfe6f}, // CJK compatibility forms
+	{0xff00, 0xff60}, // fullwidth forms
+	{0xffe0, 0xffe6},
+	{0x1f300, 0x1f64f}, // pictographs and emoticons
+	{0x1f900, 0x1f9ff}, // supplemental symbols and pictographs
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+// IsWide reports whether r is a double-width character, which takes
+// two cells on a terminal.
+func IsWide(r rune) bool {
+	if r < wideRanges[0][0] {
+		return false
+	}
+	low, high := 0, len(wideRanges)
+	for low < high {
+		mid := (low + high) / 2
+		switch {
+		case r < wideRanges[mid][0]:
+			high = mid
+		case r > wideRanges[mid][1]:
+			low = mid + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -1,20 +1,191 @@
 package vt
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Attribute is a set of rendition flags: bold, underline, etc.
+type Attribute uint16
+
 const (
-	VT100AttrBold Attribute = 1 << (16 + iota)
+	VT100AttrBold Attribute = 1 << iota
 	VT100AttrDim
 	VT100AttrItalic
 	VT100AttrUnderline
 	VT100AttrBlink
 	VT100AttrInverse
+	VT100AttrProtected // DECSCA, not SGR
 )
 
-type AttrMode int
+var attrNames = []string{
+	"bold", "dim", "italic", "underline", "blink", "inverse", "protected",
+}
+
+// String returns the flag names in the set, separated by spaces.
+func (a Attribute) String() string {
+	names := []string{}
+	for i, name := range attrNames {
+		if a&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// ColorKind distinguishes the default color from palette and RGB colors.
+type ColorKind uint8
 
 const (
-	AttrModeNorm AttrMode = iota
-	AttrMode38a
-	AttrMode38b
-	AttrMode48a
-	AttrMode48b
+	ColorDefault ColorKind = iota
+	ColorIndexed
+	ColorRGB
 )
+
+// Color is a foreground or background color. The zero value is the
+// terminal's default color.
+type Color uint32
+
+// DefaultColor is the terminal's default foreground or background.
+const DefaultColor Color = 0
+
+// IndexedColor returns palette color n: 0-7 are the ANSI colors, 8-15
+// their bright variants and 16-255 the xterm 256-color cube and greys.
+func IndexedColor(n uint8) Color {
+	return Color(ColorIndexed)<<24 | Color(n)
+}
+
+// RGBColor returns a direct 24-bit color.
+func RGBColor(r, g, b uint8) Color {
+	return Color(ColorRGB)<<24 | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// Kind returns whether the color is the default, indexed or RGB.
+func (c Color) Kind() ColorKind {
+	return ColorKind(c >> 24)
+}
+
+// IsDefault reports whether c is the terminal's default color.
+func (c Color) IsDefault() bool {
+	return c.Kind() == ColorDefault
+}
+
+// Index returns the palette index of an indexed color; ok is false for
+// default and RGB colors.
+func (c Color) Index() (n uint8, ok bool) {
+	return uint8(c), c.Kind() == ColorIndexed
+}
+
+// RGB returns the components of an RGB color; ok is false for default
+// and indexed colors.
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), c.Kind() == ColorRGB
+}
+
+// valid reports whether c is a color the constructors can make.
+func (c Color) valid() bool {
+	switch c.Kind() {
+	case ColorDefault:
+		return c == DefaultColor
+	case ColorIndexed:
+		return c&0xffff00 == 0
+	case ColorRGB:
+		return true
+	}
+	return false
+}
+
+// String returns "default", the palette index, or "#rrggbb".
+func (c Color) String() string {
+	switch c.Kind() {
+	case ColorIndexed:
+		return fmt.Sprint(uint8(c))
+	case ColorRGB:
+		return fmt.Sprintf("#%06x", uint32(c)&0xffffff)
+	}
+	return "default"
+}
+
+// Style is the rendition of a cell: its colors and attribute flags. The
+// zero value is the default style. Styles are comparable with ==.
+type Style struct {
+	fg, bg Color
+	flags  Attribute
+}
+
+// DefaultStyle returns the style of a cell in the default colors, with
+// no flags set.
+func DefaultStyle() Style {
+	return Style{}
+}
+
+// Fg returns the foreground color.
+func (s Style) Fg() Color { return s.fg }
+
+// Bg returns the background color.
+func (s Style) Bg() Color { return s.bg }
+
+// Flags returns the attribute flags.
+func (s Style) Flags() Attribute { return s.flags }
+
+// Has reports whether all the flags in a are set.
+func (s Style) Has(a Attribute) bool { return s.flags&a == a }
+
+func (s Style) Bold() bool      { return s.Has(VT100AttrBold) }
+func (s Style) Dim() bool       { return s.Has(VT100AttrDim) }
+func (s Style) Italic() bool    { return s.Has(VT100AttrItalic) }
+func (s Style) Underline() bool { return s.Has(VT100AttrUnderline) }
+func (s Style) Blink() bool     { return s.Has(VT100AttrBlink) }
+func (s Style) Inverse() bool   { return s.Has(VT100AttrInverse) }
+func (s Style) Protected() bool { return s.Has(VT100AttrProtected) }
+
+// valid reports whether s has valid colors and only known flags.
+func (s Style) valid() bool {
+	return s.fg.valid() && s.bg.valid() && s.flags < VT100AttrProtected<<1
+}
+
+// IsDefault reports whether s is the default style.
+func (s Style) IsDefault() bool { return s == Style{} }
+
+// WithFg returns s with foreground c.
+func (s Style) WithFg(c Color) Style {
+	s.fg = c
+	return s
+}
+
+// WithBg returns s with background c.
+func (s Style) WithBg(c Color) Style {
+	s.bg = c
+	return s
+}
+
+// With returns s with the flags in a set.
+func (s Style) With(a Attribute) Style {
+	s.flags |= a
+	return s
+}
+
+// Without returns s with the flags in a cleared.
+func (s Style) Without(a Attribute) Style {
+	s.flags &^= a
+	return s
+}
+
+// String describes the style as space-separated fg=, bg= and flag
+// names, omitting defaults; the default style is "default".
+func (s Style) String() string {
+	parts := []string{}
+	if !s.fg.IsDefault() {
+		parts = append(parts, "fg="+s.fg.String())
+	}
+	if !s.bg.IsDefault() {
+		parts = append(parts, "bg="+s.bg.String())
+	}
+	if s.flags != 0 {
+		parts = append(parts, s.flags.String())
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, " ")
+}
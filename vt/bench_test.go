@@ -0,0 +1,133 @@
+package vt
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/greensnark/go-footv/compfile"
+	"github.com/greensnark/go-footv/ttyrec"
+)
+
+// loadFrames reads the bodies of the test ttyrec.
+func loadFrames(b *testing.B) [][]byte {
+	file, err := compfile.Open("../ttyrec/test/test.ttyrec.gz")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	var frames [][]byte
+	reader := ttyrec.Reader(file)
+	for {
+		frame, err := reader.ReadFrame()
+		if err == io.EOF {
+			return frames
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		frames = append(frames, append([]byte(nil), frame.Body...))
+	}
+}
+
+func BenchmarkPlayTtyrec(b *testing.B) {
+	frames := loadFrames(b)
+	size := 0
+	for _, frame := range frames {
+		size += len(frame)
+	}
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		term := New()
+		for _, frame := range frames {
+			term.Write(frame)
+		}
+	}
+}
+
+// BenchmarkKeyframes plays the test ttyrec taking a snapshot of every
+// frame, as building a keyframe index does.
+func BenchmarkKeyframes(b *testing.B) {
+	frames := loadFrames(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		term := New()
+		for _, frame := range frames {
+			term.Write(frame)
+			term.Snapshot()
+		}
+	}
+}
+
+// benchmarkWrite writes content to an 80x24 terminal repeatedly.
+func benchmarkWrite(b *testing.B, setup, content string) {
+	term := New()
+	term.WriteString(setup)
+	data := []byte(content)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		term.Write(data)
+	}
+}
+
+func BenchmarkLinefeed(b *testing.B) {
+	benchmarkWrite(b, "\033[24H", strings.Repeat("\n", 1000))
+}
+
+func BenchmarkScroll(b *testing.B) {
+	benchmarkWrite(b, "\033[24H", strings.Repeat("line of text\r\n", 100))
+}
+
+func BenchmarkScrollRegion(b *testing.B) {
+	benchmarkWrite(b, "\033[2;23r\033[23H",
+		strings.Repeat("line of text\r\n", 100))
+}
+
+func BenchmarkScrollback(b *testing.B) {
+	term := New()
+	term.ScrollbackLimit = 1000
+	term.WriteString("\033[24H")
+	data := []byte(strings.Repeat("line of text\r\n", 100))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		term.Write(data)
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	term := New()
+	term.WriteString("\033[24H")
+	for i := 0; i < b.N; i++ {
+		term.WriteString("line " + strconv.Itoa(i) + "\r\n")
+		term.Snapshot()
+	}
+}
+
+func BenchmarkWriteASCII(b *testing.B) {
+	benchmarkWrite(b, "", strings.Repeat(
+		"The quick brown fox jumps over the lazy dog.\r\n", 50))
+}
+
+func BenchmarkWriteUTF8(b *testing.B) {
+	benchmarkWrite(b, "", strings.Repeat(
+		"Ça va très bien, merci — ☺ ±½°\r\n", 50))
+}
+
+func BenchmarkWriteSGR(b *testing.B) {
+	benchmarkWrite(b, "", strings.Repeat(
+		"\033[1;33m@\033[0m \033[32m#####\033[37m....\033[0m\r\n", 50))
+}
+
+func BenchmarkWriteCursorMoves(b *testing.B) {
+	benchmarkWrite(b, "", strings.Repeat(
+		"\033[10;20Hab\033[12;40Hcd\033[Kef\033[3;1H\033[1@gh", 50))
+}
+
+func BenchmarkWriteDECGraphics(b *testing.B) {
+	benchmarkWrite(b, "", strings.Repeat(
+		"\033(0lqqqqqqqqqqqqk\033(B\r\n", 50))
+}
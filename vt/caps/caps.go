@@ -0,0 +1,174 @@
+// Package caps describes what a viewer's terminal can show, and builds
+// vt.Encoders that downgrade screens to suit it: colors to the nearest
+// the terminal has, characters to its character set, and attributes to
+// those it supports.
+package caps
+
+import (
+	"github.com/greensnark/go-footv/unicode"
+	"github.com/greensnark/go-footv/vt"
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+// Charset is the character set a terminal expects.
+type Charset int
+
+const (
+	UTF8 Charset = iota
+	CP437
+	ASCII
+)
+
+func (c Charset) String() string {
+	switch c {
+	case UTF8:
+		return "UTF-8"
+	case CP437:
+		return "CP437"
+	case ASCII:
+		return "ASCII"
+	}
+	return "unknown"
+}
+
+// Color depths for Caps.Colors.
+const (
+	Mono      = 0
+	TrueColor = 1 << 24
+)
+
+// AllAttrs is every attribute vt tracks.
+const AllAttrs = vt.VT100AttrBold | vt.VT100AttrDim | vt.VT100AttrItalic |
+	vt.VT100AttrUnderline | vt.VT100AttrBlink | vt.VT100AttrInverse |
+	vt.VT100AttrProtected
+
+// Caps are the capabilities of a terminal.
+type Caps struct {
+	// Colors is the number of colors the terminal has: Mono, 8, 16,
+	// 256 or TrueColor. With 16 colors, bright foregrounds are sent as
+	// bold, the way most 16-color terminals show them, and bright
+	// backgrounds lose their brightness.
+	Colors int
+
+	Charset Charset
+
+	// Attrs are the attributes the terminal shows; others are dropped.
+	Attrs vt.Attribute
+
+	// NoScroll is set for terminals without scrolling regions and the
+	// scroll sequences SU and SD, such as DOS's ANSI.SYS.
+	NoScroll bool
+
+	// Palette is used to find the nearest colors. If nil, xterm's
+	// colors are used.
+	Palette *palette.Palette
+}
+
+var (
+	// Modern is a UTF-8 truecolor terminal that shows everything.
+	Modern = Caps{Colors: TrueColor, Charset: UTF8, Attrs: AllAttrs}
+
+	// XTerm256 is a UTF-8 256-color terminal.
+	XTerm256 = Caps{Colors: 256, Charset: UTF8, Attrs: AllAttrs}
+
+	// Linux is the Linux console.
+	Linux = Caps{Colors: 16, Charset: UTF8, Attrs: vt.VT100AttrBold |
+		vt.VT100AttrDim | vt.VT100AttrUnderline | vt.VT100AttrBlink |
+		vt.VT100AttrInverse}
+
+	// DOS is a DOS telnet client or ANSI.SYS: 16 colors and CP437.
+	DOS = Caps{Colors: 16, Charset: CP437, Attrs: vt.VT100AttrBold |
+		vt.VT100AttrBlink | vt.VT100AttrInverse, NoScroll: true}
+
+	// VT100 is a monochrome ASCII terminal.
+	VT100 = Caps{Colors: Mono, Charset: ASCII, Attrs: vt.VT100AttrBold |
+		vt.VT100AttrUnderline | vt.VT100AttrBlink | vt.VT100AttrInverse}
+)
+
+// Encoder returns an encoder that downgrades screens for the terminal.
+// Feed it snapshots, from Tty.Snapshot or SharedTty.Snapshot.
+func (c Caps) Encoder() *vt.Encoder {
+	e := &vt.Encoder{NoScroll: c.NoScroll}
+	if c.Colors < TrueColor || c.Attrs != AllAttrs {
+		e.Style = c.Style
+	}
+	if c.Charset != UTF8 {
+		e.Glyph = c.Glyph
+	}
+	return e
+}
+
+func (c Caps) palette() *palette.Palette {
+	if c.Palette == nil {
+		return defaultPalette
+	}
+	return c.Palette
+}
+
+var defaultPalette = palette.New(nil)
+
+// Style returns the nearest style to s that the terminal can show.
+func (c Caps) Style(s vt.Style) vt.Style {
+	fg, bg := c.color(s.Fg(), true), c.color(s.Bg(), false)
+	flags := s.Flags() & c.Attrs
+	if c.Colors == 16 {
+		if n, ok := fg.Index(); ok && n >= 8 {
+			fg = vt.IndexedColor(n - 8)
+			flags |= c.Attrs & vt.VT100AttrBold
+		}
+		if n, ok := bg.Index(); ok && n >= 8 {
+			bg = vt.IndexedColor(n - 8)
+		}
+	}
+	return vt.DefaultStyle().WithFg(fg).WithBg(bg).With(flags)
+}
+
+// color returns the nearest color to col that the terminal has.
+func (c Caps) color(col vt.Color, fg bool) vt.Color {
+	switch {
+	case col.IsDefault() || c.Colors >= TrueColor:
+		return col
+	case c.Colors < 8:
+		return vt.DefaultColor
+	}
+	if n, ok := col.Index(); ok && int(n) < c.Colors {
+		return col
+	}
+	p := c.palette()
+	return vt.IndexedColor(p.Nearest(p.Color(col, fg), c.Colors))
+}
+
+// Glyph returns the bytes that draw r in the terminal's character set.
+// Characters it lacks are replaced: line drawing with +, - and |, and
+// anything else with ?, or ?? for double-width characters.
+func (c Caps) Glyph(r rune) []byte {
+	if c.Charset != UTF8 && unicode.IsWide(r) {
+		return wideFallback(r)
+	}
+	switch c.Charset {
+	case CP437:
+		if b, ok := cp437Bytes[r]; ok {
+			return byteSeq(b)
+		}
+	case ASCII:
+		if r < 0x7f {
+			return byteSeq(byte(r))
+		}
+	default:
+		return []byte(string(r))
+	}
+	return byteSeq(asciiFallback(r))
+}
+
+// byteSeqs holds every byte value, so that Glyph can return one byte
+// without allocating.
+var byteSeqs = func() (b [256]byte) {
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}()
+
+func byteSeq(b byte) []byte {
+	return byteSeqs[b : int(b)+1 : int(b)+1]
+}
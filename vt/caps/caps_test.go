@@ -0,0 +1,138 @@
+package caps
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/greensnark/go-footv/terminfo"
+	"github.com/greensnark/go-footv/vt"
+)
+
+func TestStyle(t *testing.T) {
+	s := vt.DefaultStyle()
+	brightRed := s.WithFg(vt.IndexedColor(9))
+	orange := s.WithFg(vt.RGBColor(255, 135, 0)).With(vt.VT100AttrItalic)
+	for _, want := range []struct {
+		caps Caps
+		in   vt.Style
+		out  string
+	}{
+		{Modern, orange, "fg=#ff8700 italic"},
+		{XTerm256, orange, "fg=208 italic"},
+		{XTerm256, brightRed, "fg=9"},
+		{Linux, brightRed, "fg=1 bold"},
+		{Linux, s.WithBg(vt.IndexedColor(12)), "bg=4"},
+		{Linux, s.WithFg(vt.IndexedColor(196)), "fg=1 bold"},
+		{Linux, orange, "fg=3"},
+		{Caps{Colors: 8}, brightRed, "fg=1"},
+		{DOS, s.With(vt.VT100AttrUnderline | vt.VT100AttrInverse), "inverse"},
+		{DOS, s.With(vt.VT100AttrProtected), "default"},
+		{VT100, orange.With(vt.VT100AttrBold), "bold"},
+	} {
+		if out := want.caps.Style(want.in).String(); out != want.out {
+			t.Errorf("%v for %d colors: expected %s, got %s",
+				want.in, want.caps.Colors, want.out, out)
+		}
+	}
+}
+
+func TestGlyph(t *testing.T) {
+	for _, want := range []struct {
+		charset Charset
+		in      rune
+		out     string
+	}{
+		{UTF8, '─', "─"},
+		{CP437, 'A', "A"},
+		{CP437, '─', "\xc4"},
+		{CP437, '╔', "\xc9"},
+		{CP437, 'é', "\x82"},
+		{CP437, '━', "-"},
+		{CP437, '◆', "+"},
+		{CP437, '☺', "?"},
+		{ASCII, '─', "-"},
+		{ASCII, '┃', "|"},
+		{ASCII, '┼', "+"},
+		{ASCII, '╭', "+"},
+		{ASCII, '▒', ":"},
+		{ASCII, '█', "#"},
+		{ASCII, 'é', "?"},
+		{ASCII, '中', "??"},
+		{CP437, 'Ａ', "A "},
+	} {
+		c := Caps{Charset: want.charset}
+		if out := string(c.Glyph(want.in)); out != want.out {
+			t.Errorf("%v %q: expected %q, got %q", want.charset, want.in,
+				want.out, out)
+		}
+	}
+}
+
+// box draws a box in DEC special graphics, with colored text inside.
+const box = "\033(0lqqqk\r\nx\033(B\033[38;5;208mhi\033[0m \033(0x\r\nmqqqj\033(B"
+
+func TestEncoderASCII(t *testing.T) {
+	src := vt.NewSz(vt.Pt{X: 10, Y: 3})
+	src.WriteString(box)
+	out := VT100.Encoder().Redraw(src.Snapshot())
+	for _, b := range out {
+		if b >= 0x80 {
+			t.Fatalf("non-ASCII byte %#x in %q", b, out)
+		}
+	}
+	if bytes.Contains(out, []byte("38;")) {
+		t.Errorf("color sent to a monochrome terminal: %q", out)
+	}
+
+	dst := vt.NewSz(vt.Pt{X: 10, Y: 3})
+	dst.Write(out)
+	for y, want := range []string{"+---+", "|hi |", "+---+"} {
+		if got := dst.TextAtN(vt.Pt{Y: y}, len(want)); got != want {
+			t.Errorf("row %d: expected %q, got %q", y, want, got)
+		}
+	}
+}
+
+func TestEncoderCP437(t *testing.T) {
+	src := vt.NewSz(vt.Pt{X: 10, Y: 3})
+	src.WriteString(box)
+	from := src.Snapshot()
+	src.WriteString("\033[H\033[M")
+	e := DOS.Encoder()
+	if out := e.Redraw(from); !bytes.Contains(out, []byte("\xda\xc4\xc4\xc4\xbf")) {
+		t.Errorf("expected CP437 line drawing in %q", out)
+	}
+	out := e.Diff(from, src.Snapshot())
+	if bytes.Contains(out, []byte("S")) || bytes.Contains(out, []byte("r")) {
+		t.Errorf("scrolling sent to a terminal without it: %q", out)
+	}
+	if !bytes.Contains(out, []byte("\033[33m")) {
+		t.Errorf("expected orange as color 3 in %q", out)
+	}
+}
+
+func TestFromTerminfo(t *testing.T) {
+	for _, want := range []struct {
+		file     string
+		colors   int
+		attrs    string
+		noScroll bool
+	}{
+		{"l/linux", 8, "bold dim underline blink inverse", true},
+		{"v/vt100", Mono, "bold underline blink inverse", true},
+		{"x/xterm-256color", 256, "bold dim italic underline blink inverse", false},
+		{"x/xterm-direct", TrueColor, "bold dim italic underline blink inverse", false},
+	} {
+		ti, err := terminfo.LoadFile("../../terminfo/test/" + want.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := FromTerminfo(ti)
+		if c.Colors != want.colors || c.Attrs.String() != want.attrs ||
+			c.NoScroll != want.noScroll {
+			t.Errorf("%s: expected %d colors, %s, NoScroll %v; got %d, %s, %v",
+				want.file, want.colors, want.attrs, want.noScroll,
+				c.Colors, c.Attrs, c.NoScroll)
+		}
+	}
+}
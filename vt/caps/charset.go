@@ -0,0 +1,85 @@
+package caps
+
+import "github.com/greensnark/go-footv/cset"
+
+// cp437Bytes maps runes back to the CP437 bytes that show them. The
+// bytes below 0x20 and 0x7f are left out: they draw glyphs on a DOS
+// screen but are control characters to most clients.
+var cp437Bytes = func() map[rune]byte {
+	m := make(map[rune]byte, len(cset.Cp437))
+	for b, r := range cset.Cp437 {
+		if b < 0x20 || b == 0x7f {
+			continue
+		}
+		m[r] = byte(b)
+	}
+	return m
+}()
+
+// asciiGlyphs are stand-ins for the DEC special graphics and other
+// common symbols, following ncurses's ACS fallbacks.
+var asciiGlyphs = map[rune]byte{
+	0x00a0: ' ',  // no-break space
+	0x00a3: 'f',  // £
+	0x00b0: '\'', // °
+	0x00b1: '#',  // ±
+	0x00b7: '.',  // ·
+	0x03c0: '*',  // π
+	0x2022: 'o',  // •
+	0x2190: '<',  // ←
+	0x2191: '^',  // ↑
+	0x2192: '>',  // →
+	0x2193: 'v',  // ↓
+	0x2260: '!',  // ≠
+	0x2264: '<',  // ≤
+	0x2265: '>',  // ≥
+	0x23ba: '-',  // ⎺ scan line 1
+	0x23bb: '-',  // ⎻ scan line 3
+	0x23bc: '-',  // ⎼ scan line 7
+	0x23bd: '_',  // ⎽ scan line 9
+	0x2592: ':',  // ▒ checkerboard
+	0x25c6: '+',  // ◆
+}
+
+// asciiFallback returns an ASCII stand-in for r.
+func asciiFallback(r rune) byte {
+	if b, ok := asciiGlyphs[r]; ok {
+		return b
+	}
+	switch {
+	case r >= 0x2500 && r < 0x2580:
+		return boxFallback(r)
+	case r >= 0x2580 && r < 0x25a0:
+		// Block elements.
+		return '#'
+	}
+	return '?'
+}
+
+// wideFallback returns two cells of ASCII for the double-width r: the
+// ASCII character for a fullwidth form, padded with a space.
+func wideFallback(r rune) []byte {
+	if r >= 0xff01 && r <= 0xff5e {
+		return []byte{byte(r - 0xff01 + '!'), ' '}
+	}
+	return []byte("??")
+}
+
+// boxFallback returns -, | or + for a box drawing character.
+func boxFallback(r rune) byte {
+	switch r {
+	case 0x2500, 0x2501, 0x2504, 0x2505, 0x2508, 0x2509, 0x254c, 0x254d,
+		0x2550, 0x2574, 0x2576, 0x2578, 0x257a, 0x257c, 0x257e:
+		return '-'
+	case 0x2502, 0x2503, 0x2506, 0x2507, 0x250a, 0x250b, 0x254e, 0x254f,
+		0x2551, 0x2575, 0x2577, 0x2579, 0x257b, 0x257d, 0x257f:
+		return '|'
+	case 0x2571:
+		return '/'
+	case 0x2572:
+		return '\\'
+	case 0x2573:
+		return 'X'
+	}
+	return '+'
+}
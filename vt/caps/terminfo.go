@@ -0,0 +1,48 @@
+package caps
+
+import (
+	"github.com/greensnark/go-footv/terminfo"
+	"github.com/greensnark/go-footv/vt"
+)
+
+// FromTerminfo returns the capabilities of the terminal ti describes.
+// Terminfo doesn't say which character set a terminal expects, so the
+// result assumes UTF-8; set Charset from the viewer's locale or
+// client instead.
+func FromTerminfo(ti *terminfo.Terminfo) Caps {
+	c := Caps{Charset: UTF8}
+	switch colors := ti.Number("colors"); {
+	case ti.Bool("RGB") || ti.Bool("Tc") || colors >= TrueColor:
+		c.Colors = TrueColor
+	case colors >= 256:
+		c.Colors = 256
+	case colors >= 16:
+		c.Colors = 16
+	case colors >= 8:
+		c.Colors = 8
+	}
+
+	for _, a := range []struct {
+		cap  string
+		attr vt.Attribute
+	}{
+		{"bold", vt.VT100AttrBold},
+		{"dim", vt.VT100AttrDim},
+		{"sitm", vt.VT100AttrItalic},
+		{"smul", vt.VT100AttrUnderline},
+		{"blink", vt.VT100AttrBlink},
+		{"rev", vt.VT100AttrInverse},
+	} {
+		if _, ok := ti.String(a.cap); ok {
+			c.Attrs |= a.attr
+		}
+	}
+
+	// Diff scrolls with a scrolling region and SU and SD.
+	for _, cap := range []string{"csr", "indn", "rin"} {
+		if _, ok := ti.String(cap); !ok {
+			c.NoScroll = true
+		}
+	}
+	return c
+}
@@ -0,0 +1,62 @@
+package vt
+
+// CursorShape is the shape of the cursor, as set by DECSCUSR.
+type CursorShape int
+
+const (
+	CursorBlock CursorShape = iota
+	CursorUnderline
+	CursorBar
+)
+
+func (s CursorShape) String() string {
+	switch s {
+	case CursorUnderline:
+		return "underline"
+	case CursorBar:
+		return "bar"
+	default:
+		return "block"
+	}
+}
+
+// CursorStyle describes how the recorded program wanted the cursor
+// drawn. Whether it is drawn at all is Tty.CursorVisible.
+type CursorStyle struct {
+	Shape CursorShape
+	Blink bool
+}
+
+// DefaultCursorStyle is the cursor style after a reset: a blinking
+// block, like DECSCUSR 0.
+func DefaultCursorStyle() CursorStyle {
+	return CursorStyle{Shape: CursorBlock, Blink: true}
+}
+
+// decscusr returns the DECSCUSR parameter that selects s.
+func (s CursorStyle) decscusr() int {
+	n := 2*int(s.Shape) + 1
+	if !s.Blink {
+		n++
+	}
+	return n
+}
+
+// setCursorStyle applies a DECSCUSR parameter. Unknown values are
+// ignored.
+func (t *Tty) setCursorStyle(n int) {
+	switch n {
+	case 0, 1:
+		t.CursorStyle = CursorStyle{Shape: CursorBlock, Blink: true}
+	case 2:
+		t.CursorStyle = CursorStyle{Shape: CursorBlock}
+	case 3:
+		t.CursorStyle = CursorStyle{Shape: CursorUnderline, Blink: true}
+	case 4:
+		t.CursorStyle = CursorStyle{Shape: CursorUnderline}
+	case 5:
+		t.CursorStyle = CursorStyle{Shape: CursorBar, Blink: true}
+	case 6:
+		t.CursorStyle = CursorStyle{Shape: CursorBar}
+	}
+}
@@ -0,0 +1,207 @@
+package vt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DiagnosticKind classifies a Diagnostic.
+type DiagnosticKind int
+
+const (
+	// DiagnosticUnsupported is a well-formed sequence the terminal
+	// doesn't implement.
+	DiagnosticUnsupported DiagnosticKind = iota
+	// DiagnosticMalformed is input that isn't a valid sequence.
+	DiagnosticMalformed
+	// DiagnosticInfo is a notable but normal event, such as a resize.
+	DiagnosticInfo
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case DiagnosticUnsupported:
+		return "unsupported"
+	case DiagnosticMalformed:
+		return "malformed"
+	default:
+		return "info"
+	}
+}
+
+// Diagnostic describes a problem found while parsing terminal output.
+type Diagnostic struct {
+	Kind DiagnosticKind
+	// Name identifies the problem without the details that vary
+	// between occurrences, such as "CSI ? 1049 h" or "ESC ( A", so
+	// diagnostics can be counted by name.
+	Name string
+	// Detail gives the particulars of this occurrence that Name
+	// leaves out, such as the new size of a resize.
+	Detail string
+	// Seq holds the raw bytes of the sequence so far, from its ESC.
+	// It is a copy, which the observer may keep.
+	Seq []byte
+	// State is the parser state when the problem was found.
+	State VTMode
+	// Offset is the offset of the start of Seq in the stream of bytes
+	// written to the Tty.
+	Offset int64
+	// Frame is the index of the frame being played, or -1 if the Tty
+	// isn't driven by a player.
+	Frame int
+}
+
+func (d Diagnostic) String() string {
+	where := fmt.Sprintf("offset %d", d.Offset)
+	if d.Frame >= 0 {
+		where = fmt.Sprintf("frame %d, %s", d.Frame, where)
+	}
+	name := d.Name
+	if d.Detail != "" {
+		name += " " + d.Detail
+	}
+	return fmt.Sprintf("%s: %s %q (%s)", d.Kind, name, d.Seq, where)
+}
+
+// DiagnosticObserver receives diagnostics from a Tty.
+type DiagnosticObserver interface {
+	Observe(Diagnostic)
+}
+
+// DiagnosticCount is the number of times diagnostics with a given
+// name were seen, with the first one as an example.
+type DiagnosticCount struct {
+	First Diagnostic
+	Count int
+}
+
+// DiagnosticCounter is a DiagnosticObserver that counts diagnostics
+// by name.
+type DiagnosticCounter struct {
+	counts map[string]*DiagnosticCount
+}
+
+func NewDiagnosticCounter() *DiagnosticCounter {
+	return &DiagnosticCounter{counts: map[string]*DiagnosticCount{}}
+}
+
+func (c *DiagnosticCounter) Observe(d Diagnostic) {
+	if count, ok := c.counts[d.Name]; ok {
+		count.Count++
+		return
+	}
+	c.counts[d.Name] = &DiagnosticCount{First: d, Count: 1}
+}
+
+// Count returns the number of diagnostics seen with the given name.
+func (c *DiagnosticCounter) Count(name string) int {
+	if count, ok := c.counts[name]; ok {
+		return count.Count
+	}
+	return 0
+}
+
+// Counts returns the counts for every name seen, most frequent first.
+func (c *DiagnosticCounter) Counts() []DiagnosticCount {
+	res := make([]DiagnosticCount, 0, len(c.counts))
+	for _, count := range c.counts {
+		res = append(res, *count)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].First.Name < res[j].First.Name
+	})
+	return res
+}
+
+// maxSeqLen is the most bytes of a sequence kept for diagnostics.
+const maxSeqLen = 64
+
+// recordSeqByte tracks the bytes of the current escape sequence, for
+// diagnostics.
+func (t *Tty) recordSeqByte(b byte) {
+	if b == 27 {
+		t.seqBuf = t.seqBuf[:0]
+		t.seqStart = t.offset
+	} else if t.State == VTNorm {
+		return
+	}
+	if len(t.seqBuf) < maxSeqLen {
+		t.seqBuf = append(t.seqBuf, b)
+	}
+}
+
+func (t *Tty) report(kind DiagnosticKind, name string, seq []byte, start int64) {
+	t.reportDetail(kind, name, "", seq, start)
+}
+
+// reportDetail reports a diagnostic with the particulars of this
+// occurrence in detail.
+func (t *Tty) reportDetail(kind DiagnosticKind, name, detail string, seq []byte,
+	start int64) {
+	if !t.reporting() {
+		return
+	}
+	d := Diagnostic{
+		Kind:   kind,
+		Name:   name,
+		Detail: detail,
+		Seq:    append([]byte(nil), seq...),
+		State:  t.State,
+		Offset: start,
+		Frame:  t.Frame,
+	}
+	if t.Debug {
+		fmt.Fprintln(os.Stderr, d.String())
+	}
+	if t.Observer != nil {
+		t.Observer.Observe(d)
+	}
+}
+
+// reporting returns true if anything is listening for diagnostics, so
+// that their names need not be formatted otherwise.
+func (t *Tty) reporting() bool {
+	return t.Observer != nil || t.Debug
+}
+
+// unsupported reports the current escape sequence as unsupported, with
+// a name formatted as by fmt.Sprintf.
+func (t *Tty) unsupported(format string, args ...interface{}) {
+	if t.reporting() {
+		t.report(DiagnosticUnsupported, fmt.Sprintf(format, args...),
+			t.seqBuf, t.seqStart)
+	}
+}
+
+// unsupportedCSI reports the current CSI sequence, ending in final, as
+// unsupported.
+func (t *Tty) unsupportedCSI(final byte) {
+	if t.reporting() {
+		t.report(DiagnosticUnsupported, t.csiName(final), t.seqBuf, t.seqStart)
+	}
+}
+
+// malformed reports the current escape sequence as malformed.
+func (t *Tty) malformed(name string) {
+	t.report(DiagnosticMalformed, name, t.seqBuf, t.seqStart)
+}
+
+// csiName names the CSI sequence ending in final, including its
+// private marker and intermediate byte but not its parameters.
+func (t *Tty) csiName(final byte) string {
+	name := []byte("CSI ")
+	if t.State == VTQues {
+		name = append(name, '?', ' ')
+	} else if t.statePriv != 0 {
+		name = append(name, t.statePriv, ' ')
+	}
+	if t.stateInter != 0 {
+		name = append(name, t.stateInter)
+	}
+	return string(append(name, final))
+}
@@ -0,0 +1,175 @@
+package vt
+
+import "strconv"
+
+// Diff returns a byte stream that turns a terminal showing from into one
+// showing to: their cells, cursor position, visibility and style,
+// scrolling region and current attributes. It scrolls rows that have shifted,
+// erases to the end of the line where a row ends in blanks, and moves
+// over cells that are already right.
+//
+// Like Redraw, Diff assumes origin mode and left and right margins are
+// off. If the sizes differ, it returns to.Redraw().
+func Diff(from, to *Snapshot) []byte {
+	return (&Encoder{}).Diff(from, to)
+}
+
+var blankCell = AttrChar{Ch: ' '}
+
+func isBlankRow(row []AttrChar) bool {
+	for _, c := range row {
+		if c != blankCell {
+			return false
+		}
+	}
+	return true
+}
+
+// sameRow compares rows, first by identity, since snapshots share
+// unchanged rows.
+func sameRow(a, b []AttrChar) bool {
+	if len(a) > 0 && len(b) > 0 && &a[0] == &b[0] {
+		return true
+	}
+	return rowsEqual(a, b)
+}
+
+// findScroll looks for the run of rows in to that appear n rows further
+// down (n > 0) or up (n < 0) in from, and returns the scrolling region
+// [low, high) in which scrolling up by n moves them into place. It
+// returns n == 0 if scrolling would save fewer than two rows.
+func findScroll(from, to [][]AttrChar) (low, high, n int) {
+	height := len(from)
+	// Rows already in place don't need scrolling.
+	inPlace := make([]bool, height)
+	for y := range to {
+		inPlace[y] = sameRow(from[y], to[y])
+	}
+
+	best := 1
+	for shift := 1 - height; shift < height; shift++ {
+		if shift == 0 {
+			continue
+		}
+		run, gain := 0, 0
+		for y := 0; y < height; y++ {
+			src := y + shift
+			if src < 0 || src >= height || !sameRow(from[src], to[y]) {
+				run, gain = 0, 0
+				continue
+			}
+			run++
+			if !inPlace[y] && !isBlankRow(to[y]) {
+				gain++
+			}
+			if gain > best {
+				best = gain
+				start := y - run + 1
+				if shift > 0 {
+					low, high, n = start, y+1+shift, shift
+				} else {
+					low, high, n = start+shift, y+1, shift
+				}
+			}
+		}
+	}
+	return low, high, n
+}
+
+// scroll scrolls rows [low, high) up by n, or down if n is negative,
+// on the terminal and in rows, which holds what it shows.
+func (w *ansiWriter) scroll(rows [][]AttrChar, low, high, n int) {
+	// New rows are cleared in the current background.
+	w.setStyle(Style{})
+	region := Range{Low: low, High: high}
+	if region != w.region {
+		w.setRegion(region)
+	}
+	blank := make([]AttrChar, w.size.X)
+	for i := range blank {
+		blank[i] = blankCell
+	}
+	if n > 0 {
+		w.csi(countParam(n), 'S')
+		copy(rows[low:high], rows[low+n:high])
+		for y := high - n; y < high; y++ {
+			rows[y] = blank
+		}
+	} else {
+		w.csi(countParam(-n), 'T')
+		copy(rows[low-n:high], rows[low:high+n])
+		for y := low; y < low-n; y++ {
+			rows[y] = blank
+		}
+	}
+}
+
+func countParam(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// diffRow rewrites the cells of row y that differ between cur, what the
+// terminal shows, and want.
+func (w *ansiWriter) diffRow(y int, cur, want []AttrChar) {
+	if sameRow(cur, want) {
+		return
+	}
+	// Cells from blankFrom on are blank in want; erasing from the first
+	// of them that differs clears the rest of the line in one go.
+	blankFrom := len(want)
+	for blankFrom > 0 && want[blankFrom-1] == blankCell {
+		blankFrom--
+	}
+	eraseAt, changed := -1, 0
+	for x := blankFrom; x < len(want); x++ {
+		if cur[x] != want[x] {
+			if eraseAt < 0 {
+				eraseAt = x
+			}
+			changed++
+		}
+	}
+	end := len(want)
+	if eraseAt >= 0 && changed > 3 {
+		end = eraseAt
+	}
+
+	for x := 0; x < end; x++ {
+		// A double-width character is rewritten if either half changed.
+		if want[x].Ch == WideContinuation || cur[x] == want[x] &&
+			(x+1 == len(want) || want[x+1].Ch != WideContinuation ||
+				cur[x+1] == want[x+1]) {
+			continue
+		}
+		if w.cursor.Y == y && w.cursor.X < x && w.cursor.X < w.size.X {
+			// Rewriting a short gap of unchanged cells in the current
+			// style is cheaper than moving over it.
+			gap := want[w.cursor.X:x]
+			if len(gap) <= 3 && w.inStyle(gap) {
+				for _, c := range gap {
+					w.putCell(c)
+				}
+			}
+		}
+		w.moveTo(Pt{X: x, Y: y})
+		w.putCell(want[x])
+	}
+	if end < len(want) {
+		w.moveTo(Pt{X: end, Y: y})
+		w.setStyle(Style{})
+		w.csi("", 'K')
+	}
+}
+
+// inStyle reports whether cells are all drawn in the current style.
+func (w *ansiWriter) inStyle(cells []AttrChar) bool {
+	for _, c := range cells {
+		if w.enc.style(c.Attr) != w.style {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package vt
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/greensnark/go-footv/compfile"
+	"github.com/greensnark/go-footv/ttyrec"
+)
+
+// checkDiff checks that applying Diff(from, to) to a terminal showing
+// from makes it show to.
+func checkDiff(t *testing.T, name string, from, to *Snapshot, want *Tty) []byte {
+	receiver := NewSz(from.Size())
+	receiver.Write(from.Redraw())
+	diff := Diff(from, to)
+	receiver.Write(diff)
+	if receiver.DebugDump() != want.DebugDump() {
+		t.Errorf("%s: diff %#v, expected\n%s\ngot\n%s", name, string(diff),
+			want.DebugDump(), receiver.DebugDump())
+	} else if receiver.CursorVisible != want.CursorVisible ||
+		receiver.CursorStyle != want.CursorStyle ||
+		receiver.ScrollRange != want.ScrollRange ||
+		receiver.Attr != want.Attr {
+		t.Errorf("%s: diff %#v: cursor visibility or style, scrolling "+
+			"region or attributes differ", name, string(diff))
+	}
+	return diff
+}
+
+func TestDiff(t *testing.T) {
+	for _, test := range []struct {
+		before, after string
+	}{
+		{"hello", ""},
+		{"hello", " world"},
+		{"hello world", "\033[1;3Hy\033[1;9Hz"},
+		{"abcdefghijklmnop", "\033[1;3H\033[K"},
+		{"abcdefghijklmnop", "\033[1;15H\033[K"},
+		{"\033[1;31mred\033[0m", "\033[1;2H\033[32mX"},
+		{strings.Repeat("line\r\n", 10), strings.Repeat("more\r\n", 20)},
+		{"\033[5;10r\033[5H1\r\n2\r\n3\r\n4\r\n5\r\n6",
+			"\r\n7\r\n8\033[r\033[?25l"},
+		{"\033[3;20r\033[3H" + strings.Repeat("x\r\ny\r\n", 5),
+			"\033[3H\033[2L\033[?25l\033[44m"},
+		{"a\033[1;80Hb", "\033[2;5Hc\033[1;80Hd"},
+		{"\033[?25l\033[1\"qprot", "\033[0\"q\033[?25h\033[Hx"},
+		{"\033[3 q", "\033[5 q"},
+		{"\033[4 q", "\033[0 q"},
+		{"", marshalInput},
+		{marshalInput, "\033[2J\033[H" + redrawInputs[6]},
+		{"\xe4\xb8\xad\xe6\x96\x87", "\033[1;2Hx"},
+		{"abcd", "\033[1;2H\xe4\xb8\xad"},
+		{"\xe4\xb8\xad\xe6\x96\x87", "\033[1;3H\033[31m\xe6\x96\x87"},
+	} {
+		term := New()
+		term.WriteString(test.before)
+		from := term.Snapshot()
+		term.WriteString(test.after)
+		checkDiff(t, test.before+" -> "+test.after, from, term.Snapshot(), term)
+	}
+}
+
+func TestDiffScrolls(t *testing.T) {
+	term := New()
+	for i := 0; i < 30; i++ {
+		term.WriteString(strings.Repeat(string(rune('a'+i%26)), 60) + "\r\n")
+	}
+	from := term.Snapshot()
+	term.WriteString("new line\r\n")
+	to := term.Snapshot()
+
+	diff := checkDiff(t, "scroll", from, to, term)
+	if len(diff) > len(to.Redraw())/10 {
+		t.Errorf("expected a scroll, got %#v", string(diff))
+	}
+	if !strings.Contains(string(diff), "\033[S") {
+		t.Errorf("expected SU in %#v", string(diff))
+	}
+}
+
+// TestDiffTtyrec diffs consecutive frames of the test ttyrec.
+func TestDiffTtyrec(t *testing.T) {
+	file, err := compfile.Open("../ttyrec/test/test.ttyrec.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := ttyrec.Reader(file)
+
+	term, receiver := New(), New()
+	prev := term.Snapshot()
+	total, redraws := 0, 0
+	for i := 0; ; i++ {
+		frame, err := reader.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		term.Write(frame.Body)
+		snap := term.Snapshot()
+		diff := Diff(prev, snap)
+		receiver.Write(diff)
+		if receiver.DebugDump() != term.DebugDump() {
+			t.Fatalf("frame %d: diff %#v, expected\n%s\ngot\n%s", i,
+				string(diff), term.DebugDump(), receiver.DebugDump())
+		}
+		total += len(diff)
+		redraws += len(snap.Redraw())
+		prev = snap
+	}
+	if total*5 > redraws {
+		t.Errorf("diffs total %d bytes, against %d for redraws", total, redraws)
+	}
+}
@@ -0,0 +1,124 @@
+package vt
+
+// Encoder produces Redraw and Diff streams for a terminal that may
+// lack some of the colors, attributes and characters of the screen it
+// reproduces. The zero Encoder sends everything as is, in UTF-8.
+type Encoder struct {
+	// Style, if set, maps each style to one the terminal can show.
+	Style func(Style) Style
+
+	// Glyph, if set, returns the bytes that draw r on the terminal. r
+	// is never a control character. The bytes for a double-width r
+	// must fill both its cells.
+	Glyph func(r rune) []byte
+
+	// NoScroll keeps the encoder from using scrolling regions or the
+	// scroll sequences SU and SD, for terminals that lack them.
+	NoScroll bool
+}
+
+func (e *Encoder) style(s Style) Style {
+	if e.Style == nil {
+		return s
+	}
+	return e.Style(s)
+}
+
+// Redraw returns a byte stream that reproduces s, as Snapshot.Redraw.
+// If NoScroll is set, the scrolling region is not reproduced.
+func (e *Encoder) Redraw(s *Snapshot) []byte {
+	w := newANSIWriter(s.size, e)
+	w.buf.WriteString("\033[0m\033[H\033[2J")
+
+	blank := AttrChar{Ch: ' '}
+	for y := 0; y < s.size.Y; y++ {
+		row := s.rows[y]
+		end := len(row)
+		// A wrapped row is written to the end so that the next row's
+		// first character wraps onto it.
+		wraps := s.wrapped[y] && y < s.size.Y-1
+		// The row the previous one wraps onto gets its first cell
+		// written, even if blank, or the wrap wouldn't happen.
+		wrappedOnto := y > 0 && s.wrapped[y-1]
+		if !wraps {
+			for end > 0 && row[end-1] == blank {
+				end--
+			}
+			if wrappedOnto && end == 0 {
+				end = 1
+			}
+		}
+		for x := 0; x < end; x++ {
+			if row[x].Ch == WideContinuation {
+				continue
+			}
+			if row[x] == blank && !wraps && !(x == 0 && wrappedOnto) {
+				// The screen was cleared, so skip runs of blanks unless
+				// writing spaces is shorter than moving the cursor.
+				run := 1
+				for x+run < end && row[x+run] == blank {
+					run++
+				}
+				if run > 3 || !w.style.IsDefault() {
+					x += run - 1
+					continue
+				}
+			}
+			wrapping := x == 0 && wrappedOnto &&
+				w.cursor == Pt{X: w.size.X, Y: y - 1}
+			if !wrapping {
+				w.moveTo(Pt{X: x, Y: y})
+			}
+			w.putCell(row[x])
+		}
+	}
+
+	w.finish(s)
+	if !s.cursorVisible {
+		w.csi("?25", 'l')
+	}
+	if s.cursorStyle != DefaultCursorStyle() {
+		w.setCursorStyle(s.cursorStyle)
+	}
+	return w.buf.Bytes()
+}
+
+// Diff returns a byte stream that turns a terminal showing from into one
+// showing to, as the package-level Diff. If NoScroll is set, rows that
+// have shifted are rewritten rather than scrolled.
+func (e *Encoder) Diff(from, to *Snapshot) []byte {
+	if from.size != to.size {
+		return e.Redraw(to)
+	}
+	w := newANSIWriter(to.size, e)
+	w.cursor = from.cursor
+	w.style = e.style(from.attr)
+	if !e.NoScroll {
+		w.region = from.scrollRange
+	}
+
+	rows := make([][]AttrChar, len(from.rows))
+	copy(rows, from.rows)
+	if !e.NoScroll {
+		if low, high, n := findScroll(rows, to.rows); n != 0 {
+			w.scroll(rows, low, high, n)
+		}
+	}
+
+	for y := range rows {
+		w.diffRow(y, rows[y], to.rows[y])
+	}
+
+	w.finish(to)
+	if to.cursorVisible != from.cursorVisible {
+		if to.cursorVisible {
+			w.csi("?25", 'h')
+		} else {
+			w.csi("?25", 'l')
+		}
+	}
+	if to.cursorStyle != from.cursorStyle {
+		w.setCursorStyle(to.cursorStyle)
+	}
+	return w.buf.Bytes()
+}
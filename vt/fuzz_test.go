@@ -0,0 +1,130 @@
+package vt
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fuzzSeeds are inputs that once broke the terminal, on top of the
+// vt.in test files.
+var fuzzSeeds = []string{
+	"\033[8;0;0t",
+	"\033[8;1;1tx\033[K\033[X\033[@\033[P",
+	"\033[8;65535;65535t",
+	"abcdefghijklmnopqrst\033[K",
+	"abcdefghijklmnopqrst\033[5X",
+	"abcdefghijklmnopqrst\0337\033[8;3;3t\0338x",
+	"\033[5;20H\033[s\033[8;2;2t\033[ux",
+	"\033[2;4r\033[8;3;8t\033[S\033[T\n\n\n\033M\033M",
+	"\033[?69h\033[3;5s\033[8;5;2t\033[S\033[L\033[M",
+	"\033[9999b\033[9999@\033[9999P\033[9999X",
+	"abcdefghij\r\t\033[2;1Habc\033[1;1H\033[2P\033[L",
+	"\xe4\xb8\xad\xe4\xb8\xad\xe4\xb8\xad\033[8;5;3t\033[2G\xe4\xb8\xad",
+}
+
+// FuzzWrite checks that arbitrary input never panics or leaves the
+// terminal in an inconsistent state.
+func FuzzWrite(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzWrite(t, NewSz(Pt{20, 5}), data)
+	})
+}
+
+// FuzzWriteResizable is FuzzWrite with resizing from the input
+// allowed, with and without reflow.
+func FuzzWriteResizable(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, reflow := range []bool{false, true} {
+			term := NewSz(Pt{20, 5})
+			term.Resizable = true
+			term.Reflow = reflow
+			fuzzWrite(t, term, data)
+		}
+	})
+}
+
+func addFuzzSeeds(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	for _, file := range getTestBlobs() {
+		f.Add([]byte(file.UnescapedInput()))
+	}
+}
+
+// fuzzCheckEvery is how many bytes fuzzWrite writes between checks.
+// Checking costs time in proportion to the screen, which escapes can
+// grow to maxResizeDim square, so checking after every byte would
+// spend the fuzzer's time on a few large screens.
+const fuzzCheckEvery = 64
+
+// fuzzWrite writes data to term a byte at a time and then all at once,
+// checking term every fuzzCheckEvery bytes and after each pass. Taking
+// a snapshot at each check checks that every write to a row is seen by
+// the next snapshot.
+func fuzzWrite(t *testing.T, term *Tty, data []byte) {
+	term.ScrollbackLimit = 3
+	for i := range data {
+		term.Write(data[i : i+1])
+		if (i+1)%fuzzCheckEvery != 0 && i+1 != len(data) {
+			continue
+		}
+		term.Snapshot()
+		if err := term.checkInvariants(); err != nil {
+			t.Fatalf("after %q: %v", data[:i+1], err)
+		}
+	}
+	term.Write(data)
+	if err := term.checkInvariants(); err != nil {
+		t.Fatalf("after %q twice: %v", data, err)
+	}
+}
+
+// checkInvariants returns an error if the terminal's screen, cursor,
+// ranges or scrollback are inconsistent.
+func (t *Tty) checkInvariants() error {
+	if t.Size.X < 1 || t.Size.Y < 1 {
+		return fmt.Errorf("size %s", t.Size)
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	switch {
+	case len(t.rows) != t.Size.Y || len(t.snapRows) != t.Size.Y:
+		return fmt.Errorf("%d rows, %d snapshot rows for size %s", len(t.rows),
+			len(t.snapRows), t.Size)
+	case len(t.wrapped) != t.Size.Y:
+		return fmt.Errorf("%d wrapped flags for size %s", len(t.wrapped), t.Size)
+	case len(t.tabStops) != t.Size.X:
+		return fmt.Errorf("%d tab stops for size %s", len(t.tabStops), t.Size)
+	case t.top < 0 || t.top >= t.Size.Y:
+		return fmt.Errorf("ring top %d for size %s", t.top, t.Size)
+	case len(t.Scrollback) > t.ScrollbackLimit:
+		return fmt.Errorf("%d scrollback lines, limit %d", len(t.Scrollback),
+			t.ScrollbackLimit)
+	}
+	for i, snap := range t.snapRows {
+		if snap != nil && !rowsEqual(snap, t.rows[i]) {
+			return fmt.Errorf("row %d changed without being marked for the next snapshot",
+				(i-t.top+t.Size.Y)%t.Size.Y)
+		}
+	}
+	for y := 0; y < t.Size.Y; y++ {
+		row := t.row(y)
+		if len(row) != t.Size.X {
+			return fmt.Errorf("row %d is %d wide for size %s", y, len(row), t.Size)
+		}
+		for x, c := range row {
+			lead := x > 0 && row[x-1].IsWide()
+			if (c.Ch == WideContinuation) != lead {
+				return fmt.Errorf("broken double-width character at %d,%d", x, y)
+			}
+		}
+		if row[t.Size.X-1].IsWide() {
+			return fmt.Errorf("double-width character in the last column of row %d", y)
+		}
+	}
+	return nil
+}
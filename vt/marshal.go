@@ -0,0 +1,451 @@
+package vt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// marshalMagic starts every serialized Tty, followed by the format
+// version.
+const marshalMagic = "VTTY"
+
+// marshalVersion is the current serialization format. Bump it when the
+// format changes, and keep UnmarshalBinary reading older versions.
+const marshalVersion = 1
+
+var errShortData = errors.New("vt: truncated Tty data")
+
+// MarshalBinary implements encoding.BinaryMarshaler. It records the
+// complete terminal state, including the screen, scrollback, modes and
+// any escape sequence or UTF-8 character the parser is in the middle
+// of, but not Debug, Observer or the callbacks.
+//
+// Terminals wider or taller than 1000 cells, or with more lines of
+// scrollback than ScrollbackLimit, can't be serialized.
+func (t *Tty) MarshalBinary() ([]byte, error) {
+	if t.Size.X > maxResizeDim || t.Size.Y > maxResizeDim {
+		return nil, fmt.Errorf("vt: Tty size %s too large to serialize", t.Size)
+	}
+	if len(t.Scrollback) > intMax(t.ScrollbackLimit, 0) {
+		return nil, fmt.Errorf("vt: %d scrollback lines over the limit of %d",
+			len(t.Scrollback), t.ScrollbackLimit)
+	}
+	for _, line := range t.Scrollback {
+		if len(line.Cells) > maxResizeDim {
+			return nil, fmt.Errorf("vt: scrollback line width %d too large to serialize",
+				len(line.Cells))
+		}
+	}
+	e := &encoder{}
+	e.buf.WriteString(marshalMagic)
+	e.uint(marshalVersion)
+
+	e.pt(t.Size)
+	e.pt(t.initialSize)
+	e.pt(t.Cursor)
+	e.bool(t.CursorVisible)
+	e.uint(uint64(t.CursorStyle.Shape))
+	e.bool(t.CursorStyle.Blink)
+	e.int(int64(t.Bells))
+	e.string(t.Title)
+	e.style(t.Attr)
+	e.rng(t.ScrollRange)
+	e.rng(t.MarginRange)
+	e.bool(t.MarginMode)
+	e.bool(t.OriginMode)
+	e.bool(t.Resizable)
+	e.bool(t.AutoWrap)
+	e.bool(t.Kpad)
+	e.bool(t.UTF8)
+	e.bool(t.Reflow)
+	e.bool(t.ResetSize)
+	e.int(int64(t.Frame))
+
+	// Parser state.
+	e.uint(uint64(t.State))
+	e.int(int64(t.csetSelect))
+	e.uint(uint64(t.csetShift))
+	e.int(int64(t.utfChar))
+	e.int(int64(t.utfCount))
+	e.pt(t.savedCursor)
+	e.int(int64(t.lastChar))
+	e.uint(uint64(len(t.stateTok)))
+	for _, n := range t.stateTok {
+		e.int(int64(n))
+	}
+	e.buf.WriteByte(t.stateInter)
+	e.buf.WriteByte(t.statePriv)
+	e.bool(t.stateOverflow)
+	e.int(t.offset)
+	e.bytes(t.seqBuf)
+	e.int(t.seqStart)
+	e.int(t.utfStart)
+	e.bool(t.marginWrap)
+	e.pt(t.marginWrapAt)
+	e.bytes(t.oscBuf)
+	e.bools(t.tabStops)
+
+	// Screen and scrollback.
+	e.bools(t.wrapped)
+	for y := 0; y < t.Size.Y; y++ {
+		e.cells(t.row(y))
+	}
+	e.int(int64(t.ScrollbackLimit))
+	e.uint(uint64(len(t.Scrollback)))
+	for _, line := range t.Scrollback {
+		e.bool(line.Wrapped)
+		e.uint(uint64(len(line.Cells)))
+		e.cells(line.Cells)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, restoring a
+// terminal saved by MarshalBinary. Debug, Observer and the callbacks
+// are left as they were.
+//
+// It accepts screens and scrollback lines no larger than the input can
+// resize the terminal to, maxResizeDim in each direction, so that a few
+// bytes of corrupt input can't make it allocate hundreds of megabytes.
+func (t *Tty) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(marshalMagic)) {
+		return errors.New("vt: not serialized Tty data")
+	}
+	d := &decoder{data: data[len(marshalMagic):]}
+	if version := d.uint(); d.err == nil && version != marshalVersion {
+		return fmt.Errorf("vt: unsupported Tty data version %d", version)
+	}
+
+	var r Tty
+	r.Size = d.pt()
+	if d.err == nil && (r.Size.X <= 0 || r.Size.Y <= 0 ||
+		r.Size.X > maxResizeDim || r.Size.Y > maxResizeDim) {
+		return fmt.Errorf("vt: bad Tty size %s", r.Size)
+	}
+	r.initialSize = d.pt()
+	r.Cursor = d.pt()
+	r.CursorVisible = d.bool()
+	r.CursorStyle.Shape = CursorShape(d.uint())
+	r.CursorStyle.Blink = d.bool()
+	r.Bells = int(d.int())
+	r.Title = d.string()
+	r.Attr = d.style()
+	r.ScrollRange = d.rng()
+	r.MarginRange = d.rng()
+	r.MarginMode = d.bool()
+	r.OriginMode = d.bool()
+	r.Resizable = d.bool()
+	r.AutoWrap = d.bool()
+	r.Kpad = d.bool()
+	r.UTF8 = d.bool()
+	r.Reflow = d.bool()
+	r.ResetSize = d.bool()
+	r.Frame = int(d.int())
+
+	r.State = VTMode(d.uint())
+	r.csetSelect = int(d.int())
+	r.csetShift = uint(d.uint())
+	r.utfChar = rune(d.int())
+	r.utfCount = int(d.int())
+	r.savedCursor = d.pt()
+	r.lastChar = rune(d.int())
+	ntok := d.uint()
+	if d.err == nil && (ntok < 1 || ntok > 10) {
+		return fmt.Errorf("vt: bad CSI parameter count %d", ntok)
+	}
+	r.stateTok = make([]int, 0, 10)
+	for i := uint64(0); i < ntok; i++ {
+		r.stateTok = append(r.stateTok, int(d.int()))
+	}
+	r.stateInter = d.byte()
+	r.statePriv = d.byte()
+	r.stateOverflow = d.bool()
+	r.offset = d.int()
+	r.seqBuf = d.bytes()
+	r.seqStart = d.int()
+	r.utfStart = d.int()
+	r.marginWrap = d.bool()
+	r.marginWrapAt = d.pt()
+	r.oscBuf = d.bytes()
+	r.tabStops = d.bools(r.Size.X)
+
+	r.wrapped = d.bools(r.Size.Y)
+	if d.err != nil {
+		return d.err
+	}
+	r.allocScreen()
+	for y := 0; y < r.Size.Y; y++ {
+		d.cells(r.editRow(y))
+	}
+	r.ScrollbackLimit = int(d.int())
+	nlines := d.uint()
+	for i := uint64(0); i < nlines && d.err == nil; i++ {
+		wrapped := d.bool()
+		width := d.uint()
+		if width > maxResizeDim {
+			return fmt.Errorf("vt: bad scrollback line width %d", width)
+		}
+		line := Line{Cells: make([]AttrChar, width), Wrapped: wrapped}
+		d.cells(line.Cells)
+		r.Scrollback = append(r.Scrollback, line)
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) > 0 {
+		return errors.New("vt: trailing data after Tty")
+	}
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	r.Debug = t.Debug
+	r.Observer = t.Observer
+	r.CursorMoved = t.CursorMoved
+	r.CharWritten = t.CharWritten
+	r.Cleared = t.Cleared
+	r.Scrolled = t.Scrolled
+	r.Resized = t.Resized
+	r.Flushed = t.Flushed
+	r.Bell = t.Bell
+	*t = r
+	return nil
+}
+
+// validate checks that positions and ranges in a restored terminal are
+// consistent with its size, and that its scrollback and styles are
+// ones it could have made, so that corrupt data can't cause panics or
+// odd output later.
+func (t *Tty) validate() error {
+	inRange := func(r Range, max int) bool {
+		return r.Low >= 0 && r.Low < r.High && r.High <= max
+	}
+	switch {
+	case t.Cursor.X < 0 || t.Cursor.X > t.Size.X ||
+		t.Cursor.Y < 0 || t.Cursor.Y >= t.Size.Y:
+		return fmt.Errorf("vt: cursor %s outside the screen", t.Cursor)
+	case t.savedCursor.X < 0 || t.savedCursor.X > t.Size.X ||
+		t.savedCursor.Y < 0 || t.savedCursor.Y >= t.Size.Y:
+		return fmt.Errorf("vt: saved cursor %s outside the screen",
+			t.savedCursor)
+	case !inRange(t.ScrollRange, t.Size.Y):
+		return fmt.Errorf("vt: bad scrolling region %v", t.ScrollRange)
+	case !inRange(t.MarginRange, t.Size.X):
+		return fmt.Errorf("vt: bad margins %v", t.MarginRange)
+	case t.marginWrap && (t.marginWrapAt.X < 0 || t.marginWrapAt.X > t.Size.X ||
+		t.marginWrapAt.Y < 0 || t.marginWrapAt.Y >= t.Size.Y):
+		return fmt.Errorf("vt: margin wrap position %s outside the screen",
+			t.marginWrapAt)
+	case t.utfCount < 0 || t.utfCount > 5:
+		return fmt.Errorf("vt: bad UTF-8 continuation count %d", t.utfCount)
+	case t.State < VTNorm || t.State > VTOsc:
+		return fmt.Errorf("vt: bad parser state %d", t.State)
+	case t.csetShift > 1:
+		return fmt.Errorf("vt: bad charset shift %d", t.csetShift)
+	case t.lastChar < 0:
+		return fmt.Errorf("vt: bad last character %d", t.lastChar)
+	case len(t.Scrollback) > intMax(t.ScrollbackLimit, 0):
+		return fmt.Errorf("vt: %d scrollback lines over the limit of %d",
+			len(t.Scrollback), t.ScrollbackLimit)
+	case !t.Attr.valid():
+		return fmt.Errorf("vt: bad style %s", t.Attr)
+	}
+	cellsValid := func(cells []AttrChar) error {
+		for _, c := range cells {
+			if !c.Attr.valid() {
+				return fmt.Errorf("vt: bad cell style %s", c.Attr)
+			}
+		}
+		return nil
+	}
+	for y := 0; y < t.Size.Y; y++ {
+		if err := cellsValid(t.row(y)); err != nil {
+			return err
+		}
+	}
+	for _, line := range t.Scrollback {
+		if err := cellsValid(line.Cells); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type encoder struct {
+	buf     bytes.Buffer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) uint(n uint64) {
+	e.buf.Write(e.scratch[:binary.PutUvarint(e.scratch[:], n)])
+}
+
+func (e *encoder) int(n int64) {
+	e.buf.Write(e.scratch[:binary.PutVarint(e.scratch[:], n)])
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) bools(bs []bool) {
+	e.uint(uint64(len(bs)))
+	for _, b := range bs {
+		e.bool(b)
+	}
+}
+
+func (e *encoder) pt(p Pt) {
+	e.int(int64(p.X))
+	e.int(int64(p.Y))
+}
+
+func (e *encoder) rng(r Range) {
+	e.int(int64(r.Low))
+	e.int(int64(r.High))
+}
+
+func (e *encoder) style(s Style) {
+	e.uint(uint64(s.fg))
+	e.uint(uint64(s.bg))
+	e.uint(uint64(s.flags))
+}
+
+// cells writes runs of identical cells as a count and the cell.
+func (e *encoder) cells(cells []AttrChar) {
+	for i := 0; i < len(cells); {
+		run := 1
+		for i+run < len(cells) && cells[i+run] == cells[i] {
+			run++
+		}
+		e.uint(uint64(run))
+		e.style(cells[i].Attr)
+		e.int(int64(cells[i].Ch))
+		i += run
+	}
+}
+
+// decoder reads what encoder writes. After the first error, it returns
+// zero values and keeps the error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.data = nil
+}
+
+func (d *decoder) uint() uint64 {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 {
+		d.fail(errShortData)
+		return 0
+	}
+	d.data = d.data[size:]
+	return n
+}
+
+func (d *decoder) int() int64 {
+	n, size := binary.Varint(d.data)
+	if size <= 0 {
+		d.fail(errShortData)
+		return 0
+	}
+	d.data = d.data[size:]
+	return n
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) < 1 {
+		d.fail(errShortData)
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) bool() bool {
+	return d.byte() != 0
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint()
+	if n > uint64(len(d.data)) {
+		d.fail(errShortData)
+		return nil
+	}
+	b := append([]byte(nil), d.data[:n]...)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// bools reads a []bool, which must have length n.
+func (d *decoder) bools(n int) []bool {
+	if count := d.uint(); d.err == nil && count != uint64(n) {
+		d.fail(fmt.Errorf("vt: expected %d flags, got %d", n, count))
+	}
+	if n > len(d.data) {
+		d.fail(errShortData)
+		return nil
+	}
+	bs := make([]bool, n)
+	for i := range bs {
+		bs[i] = d.bool()
+	}
+	return bs
+}
+
+func (d *decoder) pt() Pt {
+	return Pt{X: int(d.int()), Y: int(d.int())}
+}
+
+func (d *decoder) rng() Range {
+	return Range{Low: int(d.int()), High: int(d.int())}
+}
+
+func (d *decoder) style() Style {
+	return Style{
+		fg:    Color(d.uint()),
+		bg:    Color(d.uint()),
+		flags: Attribute(d.uint()),
+	}
+}
+
+// cells fills cells with runs written by encoder.cells.
+func (d *decoder) cells(cells []AttrChar) {
+	for i := 0; i < len(cells) && d.err == nil; {
+		run := d.uint()
+		c := AttrChar{Attr: d.style(), Ch: rune(d.int())}
+		if run == 0 || run > uint64(len(cells)-i) {
+			d.fail(errors.New("vt: bad cell run length"))
+			return
+		}
+		for end := i + int(run); i < end; i++ {
+			cells[i] = c
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package vt
+
+import (
+	"bytes"
+	"testing"
+)
+
+var marshalInput = "\033]2;title\007\033[?69h\033[2;20s\033[3;10r" +
+	"\033[1;31;48;2;1;2;3mhello\033(0lqk\033(B\016x\017" +
+	"\033[5;5H\033[1\"qprot\033[0\"q\033[4 q\033[3g\033[20G\033H" +
+	"caf\xc3\xa9 \xe2\x98\xba\r\n\n\n\n\n\n\n\n\n\033[?7l" +
+	"wrapping wrapping wrapping\033[?7h\033[s\033]0;icon\033\\" +
+	"\033[r\033[?69l\033[24H" + "line\r\nline 2\r\nline 3\r\n"
+
+// TestMarshalRoundTrip stops a terminal at every byte of the input,
+// restores a copy from its serialized state, and checks that both
+// end up the same after the rest of the input.
+func TestMarshalRoundTrip(t *testing.T) {
+	for split := 0; split <= len(marshalInput); split++ {
+		term := NewSz(Pt{30, 24})
+		term.ScrollbackLimit = 5
+		term.WriteString(marshalInput[:split])
+		data, err := term.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var restored Tty
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("split at %d: %v", split, err)
+		}
+		again, _ := restored.MarshalBinary()
+		if !bytes.Equal(data, again) {
+			t.Fatalf("split at %d: restored terminal serializes differently",
+				split)
+		}
+
+		term.WriteString(marshalInput[split:])
+		restored.WriteString(marshalInput[split:])
+		want, _ := term.MarshalBinary()
+		got, _ := restored.MarshalBinary()
+		if !bytes.Equal(want, got) {
+			t.Fatalf("split at %d: expected\n%s\ngot\n%s", split,
+				term.DebugDump(), restored.DebugDump())
+		}
+	}
+}
+
+func TestMarshalState(t *testing.T) {
+	term := NewSz(Pt{20, 5})
+	term.ScrollbackLimit = 10
+	term.WriteString("one\r\ntwo\r\nthree\r\nfour\r\nfive\r\nsix\033]2;t\007" +
+		"\033[1;4m\033[?25l\033[3 q\033[2;4r\033[3;2H\033[12;3")
+	data, err := term.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	restored.Debug = true
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Size != term.Size || restored.Cursor != term.Cursor ||
+		restored.Title != "t" || restored.CursorVisible ||
+		restored.CursorStyle != term.CursorStyle ||
+		restored.Attr != term.Attr || restored.ScrollRange != term.ScrollRange ||
+		restored.State != VTGetPars || !restored.Debug {
+		t.Errorf("restored state differs: %+v", restored)
+	}
+	if restored.DebugDump() != term.DebugDump() {
+		t.Errorf("expected\n%s\ngot\n%s", term.DebugDump(), restored.DebugDump())
+	}
+	if len(restored.Scrollback) != 1 ||
+		restored.TextAtN(Pt{}, 3) != "two" {
+		t.Errorf("unexpected scrollback or screen contents")
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	term := New()
+	term.WriteString("hello\033[1;2")
+	data, _ := term.MarshalBinary()
+
+	for n := 0; n < len(data); n++ {
+		var restored Tty
+		if err := restored.UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("no error for data truncated to %d bytes", n)
+		}
+	}
+	var restored Tty
+	if err := restored.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Errorf("no error for trailing data")
+	}
+
+	future := append([]byte(marshalMagic), 2)
+	if err := restored.UnmarshalBinary(future); err == nil ||
+		err.Error() != "vt: unsupported Tty data version 2" {
+		t.Errorf("unexpected error for a future version: %v", err)
+	}
+
+	// Inconsistent state is rejected.
+	for _, test := range []struct {
+		name  string
+		spoil func(*Tty)
+	}{
+		{"a cursor outside the screen", func(t *Tty) { t.Cursor = Pt{3, 12} }},
+		{"a margin wrap outside the screen", func(t *Tty) {
+			t.marginWrap = true
+			t.marginWrapAt = Pt{11, 3}
+		}},
+		{"a bad UTF-8 count", func(t *Tty) { t.utfCount = 9 }},
+		{"scrollback over the limit", func(t *Tty) {
+			t.ScrollbackLimit = 1
+			t.Scrollback = make([]Line, 2)
+		}},
+		{"an unknown color kind", func(t *Tty) {
+			t.Set(Pt{1, 1}, AttrChar{Ch: 'x',
+				Attr: DefaultStyle().WithFg(Color(3 << 24))})
+		}},
+		{"an unknown color kind in the scrollback", func(t *Tty) {
+			t.ScrollbackLimit = 1
+			t.Scrollback = []Line{{Cells: []AttrChar{
+				{Attr: DefaultStyle().WithBg(Color(9 << 24))}}}}
+		}},
+		{"a bad pen color", func(t *Tty) { t.Attr = t.Attr.WithFg(IndexedColor(1) | 0x100) }},
+		{"a continuation as the last character", func(t *Tty) { t.lastChar = WideContinuation }},
+	} {
+		bad := NewSz(Pt{10, 10})
+		test.spoil(bad)
+		if bad.validate() == nil {
+			t.Errorf("validate accepted %s", test.name)
+		}
+		data, err := bad.MarshalBinary()
+		if err == nil {
+			err = restored.UnmarshalBinary(data)
+		}
+		if err == nil {
+			t.Errorf("no error for %s", test.name)
+		}
+	}
+
+	// A huge screen is rejected before anything is allocated for it.
+	huge := append([]byte(marshalMagic), 1, 0xa0, 0x1f, 0xa0, 0x1f)
+	if err := restored.UnmarshalBinary(huge); err == nil ||
+		err.Error() != "vt: bad Tty size (2000,2000)" {
+		t.Errorf("unexpected error for a 2000x2000 screen: %v", err)
+	}
+	if _, err := NewSz(Pt{2000, 2}).MarshalBinary(); err == nil {
+		t.Errorf("no error serializing a 2000x2 terminal")
+	}
+}
@@ -0,0 +1,108 @@
+// Package palette resolves terminal cell styles to concrete colors, for
+// renderers and for encoders that downgrade colors.
+package palette
+
+import "github.com/greensnark/go-footv/vt"
+
+// cubeLevels are the component values of xterm's 6x6x6 color cube.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// Xterm256 returns xterm's color for palette index n >= 16: the color
+// cube for 16-231 and the grey ramp for 232-255. Indices below 16 are
+// theme colors; Xterm256 returns the xterm theme's for those.
+func Xterm256(n uint8) RGB {
+	switch {
+	case n < 16:
+		return XTerm.ANSI[n]
+	case n < 232:
+		n -= 16
+		return RGB{cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]}
+	}
+	grey := 8 + 10*(n-232)
+	return RGB{grey, grey, grey}
+}
+
+// Palette resolves styles to colors with a theme.
+type Palette struct {
+	Theme *Theme
+
+	// BoldIsBright draws bold text in colors 0-7 in the bright colors
+	// 8-15, as many older recordings expect.
+	BoldIsBright bool
+}
+
+// New returns a palette for theme, or for XTerm if theme is nil.
+func New(theme *Theme) *Palette {
+	if theme == nil {
+		theme = XTerm
+	}
+	return &Palette{Theme: theme}
+}
+
+// Index returns palette color n.
+func (p *Palette) Index(n uint8) RGB {
+	if n < 16 {
+		return p.Theme.ANSI[n]
+	}
+	return Xterm256(n)
+}
+
+// Color returns the concrete color for c, using the theme's default
+// foreground or background for the default color.
+func (p *Palette) Color(c vt.Color, fg bool) RGB {
+	if n, ok := c.Index(); ok {
+		return p.Index(n)
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return RGB{r, g, b}
+	}
+	if fg {
+		return p.Theme.Fg
+	}
+	return p.Theme.Bg
+}
+
+// Resolve returns the foreground and background that a cell in style
+// s is drawn in, applying bold-as-bright, inverse and dim in that
+// order.
+func (p *Palette) Resolve(s vt.Style) (fg, bg RGB) {
+	fgColor := s.Fg()
+	if n, ok := fgColor.Index(); ok && n < 8 && s.Bold() && p.BoldIsBright {
+		fgColor = vt.IndexedColor(n + 8)
+	}
+	fg = p.Color(fgColor, true)
+	bg = p.Color(s.Bg(), false)
+	if s.Inverse() {
+		fg, bg = bg, fg
+	}
+	if s.Dim() {
+		fg = blend(fg, bg)
+	}
+	return fg, bg
+}
+
+// blend returns the color halfway between a and b.
+func blend(a, b RGB) RGB {
+	mid := func(x, y uint8) uint8 { return uint8((int(x) + int(y)) / 2) }
+	return RGB{mid(a.R, b.R), mid(a.G, b.G), mid(a.B, b.B)}
+}
+
+// Nearest returns the index of the palette color among the first n
+// that is closest to c, for downgrading colors to 8, 16 or 256-color
+// terminals.
+func (p *Palette) Nearest(c RGB, n int) uint8 {
+	best, bestDist := 0, -1
+	for i := 0; i < n && i < 256; i++ {
+		if d := distance(c, p.Index(uint8(i))); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return uint8(best)
+}
+
+// distance is a cheap perceptual color distance ("redmean").
+func distance(a, b RGB) int {
+	rmean := (int(a.R) + int(b.R)) / 2
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return ((512+rmean)*dr*dr)>>8 + 4*dg*dg + ((767-rmean)*db*db)>>8
+}
@@ -0,0 +1,118 @@
+package palette
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/greensnark/go-footv/vt"
+)
+
+func TestXterm256(t *testing.T) {
+	for _, want := range []struct {
+		n   uint8
+		hex string
+	}{
+		{1, "#cd0000"},
+		{16, "#000000"},
+		{21, "#0000ff"},
+		{196, "#ff0000"},
+		{231, "#ffffff"},
+		{232, "#080808"},
+		{255, "#eeeeee"},
+	} {
+		if hex := Xterm256(want.n).Hex(); hex != want.hex {
+			t.Errorf("color %d: expected %s, got %s", want.n, want.hex, hex)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p := New(Linux)
+	red := vt.DefaultStyle().WithFg(vt.IndexedColor(1))
+	bold := red.With(vt.VT100AttrBold)
+	for _, want := range []struct {
+		style        vt.Style
+		boldIsBright bool
+		fg, bg       string
+	}{
+		{vt.DefaultStyle(), false, "#aaaaaa", "#000000"},
+		{red, false, "#aa0000", "#000000"},
+		{bold, false, "#aa0000", "#000000"},
+		{bold, true, "#ff5555", "#000000"},
+		{bold.With(vt.VT100AttrInverse), true, "#000000", "#ff5555"},
+		{red.WithBg(vt.RGBColor(0, 0, 0x80)).With(vt.VT100AttrDim),
+			false, "#550040", "#000080"},
+		{vt.DefaultStyle().WithFg(vt.IndexedColor(9)), false,
+			"#ff5555", "#000000"},
+		{vt.DefaultStyle().WithFg(vt.IndexedColor(100)).With(vt.VT100AttrBold),
+			true, "#878700", "#000000"},
+	} {
+		p.BoldIsBright = want.boldIsBright
+		fg, bg := p.Resolve(want.style)
+		if fg.Hex() != want.fg || bg.Hex() != want.bg {
+			t.Errorf("%s (bold-bright %v): expected %s on %s, got %s on %s",
+				want.style, want.boldIsBright, want.fg, want.bg,
+				fg.Hex(), bg.Hex())
+		}
+	}
+}
+
+func TestThemes(t *testing.T) {
+	if names := Names(); len(names) != 3 ||
+		names[0] != "linux" || names[2] != "xterm" {
+		t.Errorf("unexpected theme names: %v", names)
+	}
+	theme, ok := Lookup("solarized")
+	if !ok || theme != Solarized {
+		t.Fatalf("solarized theme not found")
+	}
+	if fg, bg := New(theme).Resolve(vt.DefaultStyle()); fg != theme.Fg ||
+		bg != theme.Bg {
+		t.Errorf("default style is not the theme's default colors")
+	}
+	if New(nil).Theme != XTerm {
+		t.Errorf("expected the xterm theme by default")
+	}
+}
+
+// TestRegisterConcurrent re-registers themes while looking them up, for
+// the race detector.
+func TestRegisterConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Register(XTerm)
+		}()
+		go func() {
+			defer wg.Done()
+			Lookup("xterm")
+			Names()
+		}()
+	}
+	wg.Wait()
+	if theme, _ := Lookup("xterm"); theme != XTerm {
+		t.Errorf("xterm theme lost")
+	}
+}
+
+func TestNearest(t *testing.T) {
+	p := New(XTerm)
+	for _, want := range []struct {
+		c    RGB
+		n    int
+		want uint8
+	}{
+		{RGB{0xff, 0, 0}, 16, 9},
+		{RGB{0xc0, 0x10, 0x10}, 8, 1},
+		{RGB{0xff, 0, 0}, 256, 9},
+		{RGB{0x87, 0x87, 0}, 256, 100},
+		{RGB{0x80, 0x80, 0x80}, 256, 244},
+	} {
+		if got := p.Nearest(want.c, want.n); got != want.want {
+			t.Errorf("nearest of %d to %s: expected %d, got %d",
+				want.n, want.c.Hex(), want.want, got)
+		}
+	}
+}
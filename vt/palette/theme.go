@@ -0,0 +1,109 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"sync"
+)
+
+// RGB is a concrete 24-bit color.
+type RGB struct {
+	R, G, B uint8
+}
+
+// Hex returns the color as "#rrggbb".
+func (c RGB) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// RGBA returns the color as an opaque image/color value.
+func (c RGB) RGBA() color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff}
+}
+
+func hex(v uint32) RGB {
+	return RGB{uint8(v >> 16), uint8(v >> 8), uint8(v)}
+}
+
+// Theme is a named set of default and ANSI colors. Palette colors
+// 16-255 are always the xterm cube and grey ramp.
+type Theme struct {
+	Name   string
+	Fg, Bg RGB     // default foreground and background
+	ANSI   [16]RGB // colors 0-7 and their bright variants 8-15
+}
+
+func newTheme(name string, fg, bg uint32, ansi [16]uint32) *Theme {
+	t := &Theme{Name: name, Fg: hex(fg), Bg: hex(bg)}
+	for i, v := range ansi {
+		t.ANSI[i] = hex(v)
+	}
+	return t
+}
+
+var (
+	// XTerm is xterm's default palette, light grey on black.
+	XTerm = newTheme("xterm", 0xe5e5e5, 0x000000, [16]uint32{
+		0x000000, 0xcd0000, 0x00cd00, 0xcdcd00,
+		0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+		0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00,
+		0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+	})
+
+	// Linux is the Linux console's VGA palette.
+	Linux = newTheme("linux", 0xaaaaaa, 0x000000, [16]uint32{
+		0x000000, 0xaa0000, 0x00aa00, 0xaa5500,
+		0x0000aa, 0xaa00aa, 0x00aaaa, 0xaaaaaa,
+		0x555555, 0xff5555, 0x55ff55, 0xffff55,
+		0x5555ff, 0xff55ff, 0x55ffff, 0xffffff,
+	})
+
+	// Solarized is the dark Solarized palette.
+	Solarized = newTheme("solarized", 0x839496, 0x002b36, [16]uint32{
+		0x073642, 0xdc322f, 0x859900, 0xb58900,
+		0x268bd2, 0xd33682, 0x2aa198, 0xeee8d5,
+		0x002b36, 0xcb4b16, 0x586e75, 0x657b83,
+		0x839496, 0x6c71c4, 0x93a1a1, 0xfdf6e3,
+	})
+)
+
+var (
+	themesMu sync.RWMutex
+	themes   = map[string]*Theme{}
+)
+
+func init() {
+	for _, t := range []*Theme{XTerm, Linux, Solarized} {
+		Register(t)
+	}
+}
+
+// Register adds a theme to those found by Lookup, replacing any theme
+// of the same name. It is safe to call concurrently with Lookup and
+// Names.
+func Register(t *Theme) {
+	themesMu.Lock()
+	defer themesMu.Unlock()
+	themes[t.Name] = t
+}
+
+// Lookup returns the registered theme with the given name.
+func Lookup(name string) (*Theme, bool) {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	t, ok := themes[name]
+	return t, ok
+}
+
+// Names returns the names of the registered themes, sorted.
+func Names() []string {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -31,6 +31,13 @@ func intMin(a, b int) int {
 	return b
 }
 
+func intMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // PointMin returns the int minimum of the X and Y coordinates of the
 // points a and b. In other words, if a and b are treated as rectangle
 // sizes, this returns the size of the intersection of the two
@@ -44,3 +51,24 @@ type Range struct {
 }
 
 func (p Range) Span() int { return p.High - p.Low }
+
+// Rect is the rectangle of cells from Min to Max, excluding Max.
+type Rect struct {
+	Min, Max Pt
+}
+
+// Intersect returns the part of r inside s, which is empty if they
+// don't overlap.
+func (r Rect) Intersect(s Rect) Rect {
+	r.Min = Pt{X: intMax(r.Min.X, s.Min.X), Y: intMax(r.Min.Y, s.Min.Y)}
+	r.Max = PointMin(r.Max, s.Max)
+	if r.Empty() {
+		return Rect{}
+	}
+	return r
+}
+
+// Empty reports whether r contains no cells.
+func (r Rect) Empty() bool {
+	return r.Min.X >= r.Max.X || r.Min.Y >= r.Max.Y
+}
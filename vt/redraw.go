@@ -0,0 +1,275 @@
+package vt
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ansiWriter builds a byte stream for a terminal, tracking the cursor
+// position and style the receiving terminal will have, so that it only
+// emits the sequences needed to change them.
+type ansiWriter struct {
+	buf    bytes.Buffer
+	size   Pt
+	cursor Pt // X == size.X when a wrap is pending
+	style  Style
+	region Range // the scrolling region
+	enc    *Encoder
+}
+
+func newANSIWriter(size Pt, enc *Encoder) *ansiWriter {
+	return &ansiWriter{size: size, region: Range{Low: 0, High: size.Y}, enc: enc}
+}
+
+func (w *ansiWriter) csi(params string, final byte) {
+	w.buf.WriteString("\033[")
+	w.buf.WriteString(params)
+	w.buf.WriteByte(final)
+}
+
+// moveTo moves the cursor to p, which must be on the screen, with the
+// shortest of absolute and relative moves. It avoids bare LF, which
+// some terminals treat as a newline and others only as a move down.
+func (w *ansiWriter) moveTo(p Pt) {
+	if w.cursor == p {
+		return
+	}
+	best := cup(p)
+	if rel, ok := w.relativeMove(p); ok && len(rel) < len(best) {
+		best = rel
+	}
+	w.buf.WriteString(best)
+	w.cursor = p
+}
+
+// relativeMove returns relative moves from the cursor to p, if there
+// are any that are safe.
+func (w *ansiWriter) relativeMove(p Pt) (string, bool) {
+	c := w.cursor
+	dy := p.Y - c.Y
+	if dy != 0 && w.region != (Range{Low: 0, High: w.size.Y}) {
+		// Vertical moves stop at the scrolling region's margins.
+		return "", false
+	}
+
+	horizontal := "\r" + repeatSeq(p.X, "C")
+	if c.X < w.size.X {
+		// No wrap is pending.
+		switch dx := p.X - c.X; {
+		case dx == 0:
+			horizontal = ""
+		case dx > 0:
+			horizontal = shortest(horizontal, repeatSeq(dx, "C"))
+		default:
+			horizontal = shortest(horizontal, repeatSeq(-dx, "D"),
+				strings.Repeat("\b", -dx))
+		}
+	}
+
+	switch {
+	case dy > 0:
+		return shortest(repeatSeq(dy, "B")+horizontal,
+			strings.Repeat("\r\n", dy)+repeatSeq(p.X, "C")), true
+	case dy < 0:
+		return repeatSeq(-dy, "A") + horizontal, true
+	}
+	return horizontal, true
+}
+
+// repeatSeq returns the CSI sequence with the given final that moves
+// the cursor n times, or "" for zero.
+func repeatSeq(n int, final string) string {
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return "\033[" + final
+	}
+	return "\033[" + strconv.Itoa(n) + final
+}
+
+func shortest(options ...string) string {
+	best := options[0]
+	for _, o := range options[1:] {
+		if len(o) < len(best) {
+			best = o
+		}
+	}
+	return best
+}
+
+// cup returns the CUP sequence for p.
+func cup(p Pt) string {
+	switch {
+	case p == Pt{}:
+		return "\033[H"
+	case p.X == 0:
+		return "\033[" + strconv.Itoa(p.Y+1) + "H"
+	}
+	return "\033[" + strconv.Itoa(p.Y+1) + ";" + strconv.Itoa(p.X+1) + "H"
+}
+
+// setRegion sets the scrolling region, which homes the cursor.
+func (w *ansiWriter) setRegion(r Range) {
+	if r == (Range{Low: 0, High: w.size.Y}) {
+		w.csi("", 'r')
+	} else {
+		w.csi(strconv.Itoa(r.Low+1)+";"+strconv.Itoa(r.High), 'r')
+	}
+	w.region = r
+	w.cursor = Pt{}
+}
+
+// setStyle switches the terminal's rendition to s, as the encoder maps
+// it, including DECSCA protection, which SGR leaves alone.
+func (w *ansiWriter) setStyle(s Style) {
+	s = w.enc.style(s)
+	if s.Protected() != w.style.Protected() {
+		if s.Protected() {
+			w.csi("1\"", 'q')
+		} else {
+			w.csi("0\"", 'q')
+		}
+	}
+	from := w.style.Without(VT100AttrProtected)
+	if to := s.Without(VT100AttrProtected); to != from {
+		w.csi(sgrParams(from, to), 'm')
+	}
+	w.style = s
+}
+
+var sgrFlags = []struct {
+	flag Attribute
+	code string
+}{
+	{VT100AttrBold, "1"},
+	{VT100AttrDim, "2"},
+	{VT100AttrItalic, "3"},
+	{VT100AttrUnderline, "4"},
+	{VT100AttrBlink, "5"},
+	{VT100AttrInverse, "7"},
+}
+
+// sgrParams returns SGR parameters that change the rendition from from
+// to to, resetting first if to lacks flags that from has.
+func sgrParams(from, to Style) string {
+	var params []string
+	if to.IsDefault() || !to.Has(from.Flags()) ||
+		(from.Bold() && to.Dim()) || (from.Dim() && to.Bold()) {
+		params = append(params, "0")
+		from = Style{}
+	}
+	for _, f := range sgrFlags {
+		if to.Has(f.flag) && !from.Has(f.flag) {
+			params = append(params, f.code)
+		}
+	}
+	if to.Fg() != from.Fg() {
+		params = append(params, colorParams(to.Fg(), 30))
+	}
+	if to.Bg() != from.Bg() {
+		params = append(params, colorParams(to.Bg(), 40))
+	}
+	var buf bytes.Buffer
+	for i, p := range params {
+		if i > 0 {
+			buf.WriteByte(';')
+		}
+		buf.WriteString(p)
+	}
+	return buf.String()
+}
+
+// colorParams returns the SGR parameters selecting c as a foreground
+// (base 30) or background (base 40) color.
+func colorParams(c Color, base int) string {
+	if n, ok := c.Index(); ok {
+		if n < 8 {
+			return strconv.Itoa(base + int(n))
+		}
+		return strconv.Itoa(base+8) + ";5;" + strconv.Itoa(int(n))
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(r)) + ";" +
+			strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+	}
+	return strconv.Itoa(base + 9)
+}
+
+// setCursorStyle sets the cursor shape and blink with DECSCUSR.
+func (w *ansiWriter) setCursorStyle(s CursorStyle) {
+	w.csi(strconv.Itoa(s.decscusr())+" ", 'q')
+}
+
+// putCell writes c at the cursor, which advances, or waits to wrap at
+// the end of the line. Double-width characters advance it two cells,
+// and the cells their right halves cover are skipped.
+func (w *ansiWriter) putCell(c AttrChar) {
+	if c.Ch == WideContinuation {
+		return
+	}
+	w.setStyle(c.Attr)
+	ch := c.Ch
+	if ch < ' ' || (ch >= 0x7f && ch < 0xa0) || !utf8.ValidRune(ch) {
+		ch = ' '
+	}
+	if w.enc.Glyph != nil {
+		w.buf.Write(w.enc.Glyph(ch))
+	} else {
+		var enc [utf8.UTFMax]byte
+		w.buf.Write(enc[:utf8.EncodeRune(enc[:], ch)])
+	}
+	if w.cursor.X == w.size.X {
+		// The pending wrap happened.
+		w.cursor = Pt{X: 0, Y: w.cursor.Y + 1}
+	}
+	w.cursor.X++
+	if c.IsWide() {
+		w.cursor.X++
+	}
+}
+
+// finish sets the scrolling region, cursor position and current style
+// of s, once the cells are drawn.
+func (w *ansiWriter) finish(s *Snapshot) {
+	if s.scrollRange != w.region && !w.enc.NoScroll {
+		w.setRegion(s.scrollRange)
+	}
+	if s.cursor.X == s.size.X {
+		// Rewrite the last character of the row to leave a wrap
+		// pending.
+		last := Pt{X: s.size.X - 1, Y: s.cursor.Y}
+		if s.Get(last).Ch == WideContinuation {
+			last.X--
+		}
+		w.moveTo(last)
+		w.putCell(s.Get(last))
+	} else {
+		w.moveTo(s.cursor)
+	}
+	w.setStyle(s.attr)
+}
+
+// Redraw returns a byte stream that reproduces the screen on a terminal
+// of the same size: its cells and their attributes, the cursor
+// position, visibility and style, the scrolling region and the current
+// attributes for new text. Characters are sent as UTF-8 whatever
+// character set produced them. Soft-wrapped rows are redrawn so that
+// they wrap in the same place.
+//
+// The stream starts by resetting attributes and clearing the screen,
+// but otherwise assumes the terminal is in its initial state. Origin
+// mode, autowrap and the left and right margins are not restored: the
+// stream relies on autowrap being on and no margins being set, and
+// leaves them that way.
+func (t *Tty) Redraw() []byte {
+	return t.Snapshot().Redraw()
+}
+
+// Redraw returns a byte stream that reproduces the snapshot, as
+// Tty.Redraw.
+func (s *Snapshot) Redraw() []byte {
+	return (&Encoder{}).Redraw(s)
+}
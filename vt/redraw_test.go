@@ -0,0 +1,83 @@
+package vt
+
+import (
+	"strings"
+	"testing"
+)
+
+var redrawInputs = []string{
+	"",
+	"Hello, world",
+	"\033[3;5Hx\033[10;70Hy\033[24;80Hz",
+	"a   b    c\033[1;31m d \033[0m  e\033[7m   \033[0m",
+	strings.Repeat("soft wrapped text ", 12) + "\r\nnext\033[5;80H",
+	"\033[?7l" + strings.Repeat("not wrapped ", 10),
+	"\033[1;3;4;5;7;31;44mall\033[22;2mdim\033[0;38;5;200;48;2;1;2;3mrgb" +
+		"\033[38;5;9;48;5;12mbright\033[39;49;1mbold",
+	"\033(0lqqk\r\nx  x\r\nmqqj\033(B caf\xc3\xa9 \xe2\x98\xba",
+	"\033[1\"qprot\033[0\"qnot\033[1\"q\033[2;4H",
+	"\033[5;20r\033[10;3Hregion\033[?25l",
+	"\033[24;1H" + strings.Repeat("x", 80),
+	strings.Repeat("x", 80) + " \r\n" + strings.Repeat("y", 80) + "     z",
+	"end of line\033[1;80HX",
+	"\033[1;33mpen",
+	"\033[1;33mpen\033[0m  \033[1\"q",
+	strings.Repeat("line\r\n", 30) + "\033[2;3r",
+	"\xe4\xb8\xad\xe6\x96\x87 wide\033[1;79H\xe5\xad\x97",
+	"\033[1;80H\xe5\xad\x97\033[1;1H\033[7m\xe5\xad\x97",
+	"\033[4 qunderline",
+	"\033[2 q\033[?25l",
+}
+
+// TestRedraw checks that writing the redraw of a terminal to a fresh
+// one reproduces it.
+func TestRedraw(t *testing.T) {
+	for _, input := range append(redrawInputs, marshalInput) {
+		term := New()
+		term.WriteString(input)
+		redraw := term.Redraw()
+
+		fresh := New()
+		fresh.Write(redraw)
+		if fresh.DebugDump() != term.DebugDump() {
+			t.Errorf("%#v: redraw %#v, expected\n%s\ngot\n%s", input,
+				string(redraw), term.DebugDump(), fresh.DebugDump())
+			continue
+		}
+		if fresh.CursorVisible != term.CursorVisible ||
+			fresh.CursorStyle != term.CursorStyle ||
+			fresh.ScrollRange != term.ScrollRange ||
+			fresh.Attr != term.Attr {
+			t.Errorf("%#v: redraw %#v: cursor visibility or style, "+
+				"scrolling region or attributes differ", input, string(redraw))
+		}
+		for y := range term.wrapped {
+			if fresh.wrapped[y] != term.wrapped[y] {
+				t.Errorf("%#v: row %d wrapped=%v, expected %v", input, y,
+					fresh.wrapped[y], term.wrapped[y])
+			}
+		}
+	}
+}
+
+func TestRedrawMinimal(t *testing.T) {
+	for _, test := range []struct {
+		input, redraw string
+	}{
+		{"", "\033[0m\033[H\033[2J"},
+		{"hi", "\033[0m\033[H\033[2Jhi"},
+		{"a  b\r\n\r\n\033[1mc\033[0m",
+			"\033[0m\033[H\033[2Ja  b\033[3H\033[1mc\033[0m"},
+		{"a\033[10Cb\r\nc\033[2;1H",
+			"\033[0m\033[H\033[2Ja\033[10Cb\r\nc\r"},
+		{"\033[6 q", "\033[0m\033[H\033[2J\033[6 q"},
+		{"\033[6 q\033[0 q", "\033[0m\033[H\033[2J"},
+	} {
+		term := New()
+		term.WriteString(test.input)
+		if redraw := string(term.Redraw()); redraw != test.redraw {
+			t.Errorf("%#v: expected redraw %#v, got %#v",
+				test.input, test.redraw, redraw)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package vt
+
+// isBlank returns true for cells that an erase in the default
+// attributes would leave behind.
+func isBlank(c AttrChar) bool {
+	return c.Ch == ' ' && c.Attr.IsDefault()
+}
+
+func trimBlanks(cells []AttrChar) []AttrChar {
+	end := len(cells)
+	for end > 0 && isBlank(cells[end-1]) {
+		end--
+	}
+	return cells[:end]
+}
+
+// lastUsedRow returns the last screen row that isn't blank, or the
+// cursor row if that is further down.
+func (t *Tty) lastUsedRow() int {
+	for y := t.Size.Y - 1; y > t.Cursor.Y; y-- {
+		if len(trimBlanks(t.row(y))) > 0 || t.wrapped[y] {
+			return y
+		}
+	}
+	return t.Cursor.Y
+}
+
+// logicalLines joins the scrollback and the used part of the screen
+// into unwrapped lines, trimmed of trailing blanks. It also returns
+// the index of the line the cursor is on, and the cursor's offset in
+// that line.
+func (t *Tty) logicalLines() (lines [][]AttrChar, cursorLine, cursorOffset int) {
+	var line []AttrChar
+	addRow := func(cells []AttrChar, wrapped bool) {
+		if wrapped {
+			line = append(line, cells...)
+			return
+		}
+		line = append(line, trimBlanks(cells)...)
+		lines = append(lines, line)
+		line = nil
+	}
+
+	for _, l := range t.Scrollback {
+		addRow(l.Cells, l.Wrapped)
+	}
+	lastRow := t.lastUsedRow()
+	for y := 0; y <= lastRow; y++ {
+		if y == t.Cursor.Y {
+			cursorLine = len(lines)
+			cursorOffset = len(line) + t.Cursor.X
+		}
+		addRow(t.row(y), t.wrapped[y] && y < lastRow)
+	}
+	return lines, cursorLine, cursorOffset
+}
+
+// reflowResize resizes the terminal, rewrapping soft-wrapped lines to
+// the new width. The cursor stays on the same logical character, and
+// the screen shows the last rows up to the cursor or the last
+// non-blank row.
+func (t *Tty) reflowResize(newsize Pt) {
+	lines, cursorLine, cursorOffset := t.logicalLines()
+	blank := AttrChar{Ch: ' '}
+
+	var rows []Line
+	var cursor Pt
+	for i, line := range lines {
+		length := len(line)
+		if i == cursorLine && cursorOffset > length {
+			length = cursorOffset
+		}
+		start := len(rows)
+		for offset := 0; offset == 0 || offset < length; offset += newsize.X {
+			cells := make([]AttrChar, newsize.X)
+			for x := range cells {
+				cells[x] = blank
+			}
+			if offset < len(line) {
+				copy(cells, line[offset:])
+				repairWideRow(cells)
+			}
+			rows = append(rows, Line{
+				Cells:   cells,
+				Wrapped: offset+newsize.X < length,
+			})
+		}
+		if i == cursorLine {
+			if cursorOffset > 0 && cursorOffset == length &&
+				cursorOffset%newsize.X == 0 {
+				// Waiting to wrap at the end of the last row.
+				cursor = Pt{X: newsize.X, Y: start + cursorOffset/newsize.X - 1}
+			} else {
+				cursor = Pt{
+					X: cursorOffset % newsize.X,
+					Y: start + cursorOffset/newsize.X,
+				}
+			}
+		}
+	}
+
+	top := len(rows) - newsize.Y
+	if top < 0 {
+		top = 0
+	}
+	t.Scrollback = nil
+	if t.ScrollbackLimit > 0 {
+		t.appendScrollback(rows[:top]...)
+	}
+
+	t.Size = newsize
+	t.allocScreen()
+	t.resizeTabStops(newsize.X)
+	t.wrapped = make([]bool, newsize.Y)
+	t.ClearRegion(0, newsize.Area())
+	for y, row := range rows[top:] {
+		copy(t.editRow(y), row.Cells)
+		t.wrapped[y] = row.Wrapped
+	}
+	t.ScrollRange = Range{Low: 0, High: newsize.Y}
+	t.MarginRange = Range{Low: 0, High: newsize.X}
+	t.Cursor = Pt{X: cursor.X, Y: cursor.Y - top}
+	t.savedCursor = Pt{
+		X: clamp(t.savedCursor.X, 0, newsize.X-1),
+		Y: clamp(t.savedCursor.Y, 0, newsize.Y-1),
+	}
+}
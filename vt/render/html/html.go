@@ -0,0 +1,229 @@
+// Package html renders terminal snapshots as HTML: a <pre> of spans,
+// one for each run of cells in the same style, colored by CSS classes
+// for palette colors and inline styles for RGB colors.
+package html
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greensnark/go-footv/vt"
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+// Options control rendering. The zero value renders with xterm's
+// colors, class prefix "vt", and no cursor.
+type Options struct {
+	// Palette supplies the colors of the stylesheet, and decides
+	// whether bold text is drawn in bright colors.
+	Palette *palette.Palette
+
+	// Prefix starts every class name. It must be a valid CSS
+	// identifier.
+	Prefix string
+
+	// Cursor marks the cursor cell, if the cursor is visible, in the
+	// snapshot's cursor shape. A block cursor swaps the cell's colors.
+	Cursor bool
+}
+
+// options returns opt with defaults filled in.
+func (opt *Options) options() Options {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	if o.Palette == nil {
+		o.Palette = palette.New(nil)
+	}
+	if o.Prefix == "" {
+		o.Prefix = "vt"
+	}
+	return o
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;",
+	`"`, "&#34;", "'", "&#39;")
+
+// Fragment returns the screen as a <pre> element, to be used with the
+// rules of Stylesheet. Trailing blank cells in the default style are
+// left out of each row.
+func Fragment(s *vt.Snapshot, opt *Options) string {
+	o := opt.options()
+	r := renderer{pal: o.Palette, prefix: o.Prefix, cursorStyle: s.CursorStyle()}
+	r.buf.WriteString(`<pre class="` + r.prefix + `">`)
+	size := s.Size()
+	cursor := vt.Pt{X: -1, Y: -1}
+	if o.Cursor && s.CursorVisible() && s.Cursor().X < size.X {
+		cursor = s.Cursor()
+		if s.Get(cursor).Ch == vt.WideContinuation {
+			cursor.X--
+		}
+	}
+	blank := vt.AttrChar{Ch: ' '}
+	for y := 0; y < size.Y; y++ {
+		row := s.Row(y)
+		end := len(row)
+		for end > 0 && row[end-1] == blank && cursor != (vt.Pt{X: end - 1, Y: y}) {
+			end--
+		}
+		for x := 0; x < end; {
+			isCursor := cursor == vt.Pt{X: x, Y: y}
+			run := x + 1
+			for !isCursor && run < end && row[run].Attr == row[x].Attr &&
+				cursor != (vt.Pt{X: run, Y: y}) {
+				run++
+			}
+			if run < end && row[run].Ch == vt.WideContinuation {
+				run++
+			}
+			r.span(row[x:run], isCursor)
+			x = run
+		}
+		if y+1 < size.Y {
+			r.buf.WriteByte('\n')
+		}
+	}
+	r.buf.WriteString("</pre>")
+	return r.buf.String()
+}
+
+// Page returns a self-contained HTML page showing the screen, titled
+// with the terminal's title.
+func Page(s *vt.Snapshot, opt *Options) string {
+	o := opt.options()
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + escaper.Replace(s.Title()) + "</title>\n")
+	b.WriteString("<style>\n" + Stylesheet(&o) + "</style>\n")
+	b.WriteString("</head>\n<body>\n")
+	b.WriteString(Fragment(s, &o))
+	b.WriteString("\n</body>\n</html>\n")
+	return b.String()
+}
+
+// Stylesheet returns the CSS rules for the classes Fragment uses: the
+// default colors, the 256 palette colors as foregrounds and
+// backgrounds, the attributes and the cursor.
+func Stylesheet(opt *Options) string {
+	o := opt.options()
+	pal, p := o.Palette, o.Prefix
+	var b strings.Builder
+	fmt.Fprintf(&b, ".%s { color: %s; background-color: %s; }\n", p,
+		pal.Theme.Fg.Hex(), pal.Theme.Bg.Hex())
+	fmt.Fprintf(&b, ".%s-inverse { color: %s; background-color: %s; }\n", p,
+		pal.Theme.Bg.Hex(), pal.Theme.Fg.Hex())
+	for n := 0; n < 256; n++ {
+		hex := pal.Index(uint8(n)).Hex()
+		fmt.Fprintf(&b, ".%s-fg%d { color: %s; }\n", p, n, hex)
+		fmt.Fprintf(&b, ".%s-bg%d { background-color: %s; }\n", p, n, hex)
+	}
+	fmt.Fprintf(&b, ".%s-bold { font-weight: bold; }\n", p)
+	fmt.Fprintf(&b, ".%s-dim { opacity: 0.6; }\n", p)
+	fmt.Fprintf(&b, ".%s-italic { font-style: italic; }\n", p)
+	fmt.Fprintf(&b, ".%s-underline { text-decoration: underline; }\n", p)
+	fmt.Fprintf(&b, ".%s-blink { animation: %s-blink 1s step-end infinite; }\n", p, p)
+	fmt.Fprintf(&b, "@keyframes %s-blink { 50%% { opacity: 0; } }\n", p)
+	fmt.Fprintf(&b, ".%s-cursor-underline { box-shadow: inset 0 -2px currentColor; }\n", p)
+	fmt.Fprintf(&b, ".%s-cursor-bar { box-shadow: inset 2px 0 currentColor; }\n", p)
+	fmt.Fprintf(&b, ".%s-cursor-blink { animation: %s-cursor-blink 1s step-end infinite; }\n", p, p)
+	fmt.Fprintf(&b, "@keyframes %s-cursor-blink { 50%% { box-shadow: none; } }\n", p)
+	// A blinking block cursor flips the swapped colors back by
+	// inverting them.
+	fmt.Fprintf(&b, ".%s-cursor-block.%s-cursor-blink { animation-name: %s-cursor-blink-block; }\n", p, p, p)
+	fmt.Fprintf(&b, "@keyframes %s-cursor-blink-block { 50%% { filter: invert(1); } }\n", p)
+	return b.String()
+}
+
+type renderer struct {
+	pal         *palette.Palette
+	prefix      string
+	cursorStyle vt.CursorStyle
+	buf         strings.Builder
+}
+
+// span writes cells, which share a style, as a span, or as bare text
+// in the default style. The cursor cell is classed "cursor", and
+// "cursor-block", "cursor-underline" or "cursor-bar" and
+// "cursor-blink" as its style has it.
+func (r *renderer) span(cells []vt.AttrChar, cursor bool) {
+	attr := cells[0].Attr
+	if cursor && r.cursorStyle.Shape == vt.CursorBlock {
+		if attr.Inverse() {
+			attr = attr.Without(vt.VT100AttrInverse)
+		} else {
+			attr = attr.With(vt.VT100AttrInverse)
+		}
+	}
+	classes, style := r.style(attr)
+	if cursor {
+		classes = append(classes, r.prefix+"-cursor",
+			r.prefix+"-cursor-"+r.cursorStyle.Shape.String())
+		if r.cursorStyle.Blink {
+			classes = append(classes, r.prefix+"-cursor-blink")
+		}
+	}
+	open := len(classes) > 0 || style != ""
+	if open {
+		r.buf.WriteString("<span")
+		if len(classes) > 0 {
+			r.buf.WriteString(` class="` + strings.Join(classes, " ") + `"`)
+		}
+		if style != "" {
+			r.buf.WriteString(` style="` + style + `"`)
+		}
+		r.buf.WriteByte('>')
+	}
+	var text strings.Builder
+	for _, c := range cells {
+		switch {
+		case c.Ch == vt.WideContinuation:
+		case c.Ch < ' ':
+			text.WriteByte(' ')
+		default:
+			text.WriteRune(c.Ch)
+		}
+	}
+	r.buf.WriteString(escaper.Replace(text.String()))
+	if open {
+		r.buf.WriteString("</span>")
+	}
+}
+
+// style returns the classes and inline style for s.
+func (r *renderer) style(s vt.Style) (classes []string, style string) {
+	fg, bg := s.Fg(), s.Bg()
+	if n, ok := fg.Index(); ok && n < 8 && s.Bold() && r.pal.BoldIsBright {
+		fg = vt.IndexedColor(n + 8)
+	}
+	if s.Inverse() {
+		fg, bg = bg, fg
+		classes = append(classes, r.prefix+"-inverse")
+	}
+	var styles []string
+	addColor := func(c vt.Color, class, property string) {
+		if n, ok := c.Index(); ok {
+			classes = append(classes, fmt.Sprintf("%s-%s%d", r.prefix, class, n))
+		} else if red, green, blue, ok := c.RGB(); ok {
+			styles = append(styles, property+": "+
+				palette.RGB{R: red, G: green, B: blue}.Hex())
+		}
+	}
+	addColor(fg, "fg", "color")
+	addColor(bg, "bg", "background-color")
+	for _, a := range []struct {
+		flag vt.Attribute
+		name string
+	}{
+		{vt.VT100AttrBold, "bold"},
+		{vt.VT100AttrDim, "dim"},
+		{vt.VT100AttrItalic, "italic"},
+		{vt.VT100AttrUnderline, "underline"},
+		{vt.VT100AttrBlink, "blink"},
+	} {
+		if s.Has(a.flag) {
+			classes = append(classes, r.prefix+"-"+a.name)
+		}
+	}
+	return classes, strings.Join(styles, "; ")
+}
@@ -0,0 +1,74 @@
+package html
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/greensnark/go-footv/vt"
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+func snapshot(size vt.Pt, input string) *vt.Snapshot {
+	term := vt.NewSz(size)
+	term.WriteString(input)
+	return term.Snapshot()
+}
+
+func TestFragment(t *testing.T) {
+	bright := palette.New(nil)
+	bright.BoldIsBright = true
+	for _, test := range []struct {
+		input string
+		opt   *Options
+		html  string
+	}{
+		{"hi <&>", nil, `<pre class="vt">hi &lt;&amp;&gt;` + "\n</pre>"},
+		{"\033[31mred\033[0m plain", nil,
+			`<pre class="vt"><span class="vt-fg1">red</span> plain` + "\n</pre>"},
+		{"\033[1;4;5;31;44mx", &Options{Prefix: "t"},
+			`<pre class="t"><span class="t-fg1 t-bg4 t-bold t-underline t-blink">x</span>` +
+				"\n</pre>"},
+		{"\033[1;31mx", &Options{Palette: bright},
+			`<pre class="vt"><span class="vt-fg9 vt-bold">x</span>` + "\n</pre>"},
+		{"\033[7mi\033[32mg", nil,
+			`<pre class="vt"><span class="vt-inverse">i</span>` +
+				`<span class="vt-inverse vt-bg2">g</span>` + "\n</pre>"},
+		{"\033[38;2;255;128;0;48;5;200mrgb", nil,
+			`<pre class="vt"><span class="vt-bg200" style="color: #ff8000">rgb</span>` +
+				"\n</pre>"},
+		{"ab\033[1;2H", &Options{Cursor: true},
+			`<pre class="vt">a<span class="vt-inverse vt-cursor vt-cursor-block vt-cursor-blink">b</span>` +
+				"\n</pre>"},
+		{"\033[2 q\033[7mab\033[1;2H", &Options{Cursor: true},
+			`<pre class="vt"><span class="vt-inverse">a</span>` +
+				`<span class="vt-cursor vt-cursor-block">b</span>` + "\n</pre>"},
+		{"\033[4 q\033[2;3H", &Options{Cursor: true},
+			`<pre class="vt">` + "\n" + `  <span class="vt-cursor vt-cursor-underline"> </span></pre>`},
+		{"\033[5 q\033[31mab\033[1;2H", &Options{Cursor: true},
+			`<pre class="vt"><span class="vt-fg1">a</span>` +
+				`<span class="vt-fg1 vt-cursor vt-cursor-bar vt-cursor-blink">b</span>` + "\n</pre>"},
+		{"\033[?25lab\033[1;2H", &Options{Cursor: true},
+			`<pre class="vt">ab` + "\n</pre>"},
+		{"\xe4\xb8\xad\xe6\x96\x87\033[1;4H", &Options{Cursor: true},
+			"<pre class=\"vt\">\xe4\xb8\xad<span class=\"vt-inverse vt-cursor vt-cursor-block vt-cursor-blink\">\xe6\x96\x87</span>\n</pre>"},
+	} {
+		if html := Fragment(snapshot(vt.Pt{X: 10, Y: 2}, test.input), test.opt); html != test.html {
+			t.Errorf("%q: expected\n%s\ngot\n%s", test.input, test.html, html)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	page := Page(snapshot(vt.Pt{X: 6, Y: 2}, "\033]2;a<b\007hi"), nil)
+	for _, want := range []string{
+		"<title>a&lt;b</title>",
+		".vt { color: #e5e5e5; background-color: #000000; }",
+		".vt-fg196 { color: #ff0000; }",
+		".vt-cursor-bar { box-shadow: inset 2px 0 currentColor; }",
+		`<pre class="vt">hi`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in page:\n%s", want, page)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package raster renders terminal snapshots to images with a built-in
+// bitmap font, for thumbnails on machines without a browser or system
+// fonts.
+package raster
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/greensnark/go-footv/vt"
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+// The size of a cell in pixels.
+const (
+	CellWidth  = 8
+	CellHeight = 16
+)
+
+// underlineRow is the row of a cell that underlines are drawn on.
+const underlineRow = 14
+
+// replacement is drawn for characters the font doesn't have.
+var replacement = glyphs[0xfffd]
+
+// Options control rendering. The zero value renders with xterm's
+// colors and no cursor.
+type Options struct {
+	// Palette supplies the colors, and decides whether bold text is
+	// drawn in bright colors.
+	Palette *palette.Palette
+
+	// Cursor draws the cursor, in the snapshot's cursor shape, if the
+	// cursor is visible. Blinking cursors are drawn shown.
+	Cursor bool
+}
+
+// Render returns an image of the screen, CellWidth by CellHeight
+// pixels a cell. Bold text is drawn overstruck and blinking text is
+// drawn shown; italics are drawn upright. Double-width characters the
+// font doesn't have are drawn as a replacement glyph centered in their
+// two cells.
+func Render(s *vt.Snapshot, opt *Options) *image.RGBA {
+	pal := palette.New(nil)
+	if opt != nil && opt.Palette != nil {
+		pal = opt.Palette
+	}
+	size := s.Size()
+	img := image.NewRGBA(image.Rect(0, 0, size.X*CellWidth, size.Y*CellHeight))
+	cursor := vt.Pt{X: -1, Y: -1}
+	if opt != nil && opt.Cursor && s.CursorVisible() && s.Cursor().X < size.X {
+		cursor = s.Cursor()
+		if s.Get(cursor).Ch == vt.WideContinuation && cursor.X > 0 {
+			cursor.X--
+		}
+	}
+	for y := 0; y < size.Y; y++ {
+		row := s.Row(y)
+		for x, c := range row {
+			if c.Ch == vt.WideContinuation && x > 0 && row[x-1].IsWide() {
+				continue
+			}
+			cells := 1
+			if c.IsWide() && x+1 < len(row) {
+				cells = 2
+			}
+			r := image.Rect(x*CellWidth, y*CellHeight, (x+cells)*CellWidth,
+				(y+1)*CellHeight)
+			drawCell(img, r, c, pal, cursor == vt.Pt{X: x, Y: y}, s.CursorStyle().Shape)
+		}
+	}
+	return img
+}
+
+// drawCell draws c into r, one cell wide or two, with a cursor of the
+// given shape on it if cursor is set.
+func drawCell(img *image.RGBA, r image.Rectangle, c vt.AttrChar, pal *palette.Palette, cursor bool, shape vt.CursorShape) {
+	fg, bg := pal.Resolve(c.Attr)
+	if cursor && shape == vt.CursorBlock {
+		fg, bg = bg, fg
+	}
+	draw.Draw(img, r, &image.Uniform{C: bg.RGBA()}, image.Point{}, draw.Src)
+	g := glyph(c.Ch)
+	if c.Attr.Bold() && !isBox(c.Ch) {
+		for i := range g {
+			g[i] |= g[i] >> 1
+		}
+	}
+	left := r.Min.X + (r.Dx()-CellWidth)/2
+	fgColor := fg.RGBA()
+	for y, bits := range g {
+		for x := 0; x < CellWidth; x++ {
+			if bits&(0x80>>uint(x)) != 0 {
+				img.SetRGBA(left+x, r.Min.Y+y, fgColor)
+			}
+		}
+	}
+	if c.Attr.Underline() {
+		underline := image.Rect(r.Min.X, r.Min.Y+underlineRow, r.Max.X,
+			r.Min.Y+underlineRow+1)
+		draw.Draw(img, underline, &image.Uniform{C: fgColor}, image.Point{}, draw.Src)
+	}
+	if !cursor {
+		return
+	}
+	var mark image.Rectangle
+	switch shape {
+	case vt.CursorUnderline:
+		mark = image.Rect(r.Min.X, r.Max.Y-2, r.Max.X, r.Max.Y)
+	case vt.CursorBar:
+		mark = image.Rect(r.Min.X, r.Min.Y, r.Min.X+2, r.Max.Y)
+	}
+	draw.Draw(img, mark, &image.Uniform{C: fgColor}, image.Point{}, draw.Src)
+}
+
+// glyph returns the font's glyph for r, a blank for spaces and control
+// characters, or the replacement glyph.
+func glyph(r rune) [16]byte {
+	if g, ok := glyphs[r]; ok {
+		return g
+	}
+	if r < ' ' {
+		return [16]byte{}
+	}
+	return replacement
+}
+
+// isBox reports whether r is a box-drawing or block character, which
+// must fill its cell exactly to join its neighbors.
+func isBox(r rune) bool {
+	return r >= 0x2500 && r <= 0x259f
+}
@@ -0,0 +1,263 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/greensnark/go-footv/cset"
+	"github.com/greensnark/go-footv/vt"
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+func render(input string, opt *Options) *image.RGBA {
+	term := vt.NewSz(vt.Pt{X: 4, Y: 2})
+	term.WriteString(input)
+	return Render(term.Snapshot(), opt)
+}
+
+// cell returns the cell at p of img as a glyph, with the pixels in fg
+// set.
+func cell(img *image.RGBA, p vt.Pt, fg color.RGBA) (g [16]byte) {
+	for y := range g {
+		for x := 0; x < CellWidth; x++ {
+			if img.RGBAAt(p.X*CellWidth+x, p.Y*CellHeight+y) == fg {
+				g[y] |= 0x80 >> uint(x)
+			}
+		}
+	}
+	return g
+}
+
+func TestFont(t *testing.T) {
+	for n, r := range cset.Cp437[1:] {
+		if _, ok := glyphs[r]; !ok {
+			t.Errorf("no glyph for code page 437 %#02x %U", n+1, r)
+		}
+	}
+	for r := rune(0x2500); r <= 0x259f; r++ {
+		if _, ok := glyphs[r]; !ok {
+			t.Errorf("no glyph for %U", r)
+		}
+	}
+	if glyphs[' '] != [16]byte{} {
+		t.Errorf("space isn't blank")
+	}
+	if replacement == [16]byte{} {
+		t.Errorf("replacement glyph is blank")
+	}
+}
+
+// TestGlyphs compares a few rendered glyphs with the VGA ROM's.
+func TestGlyphs(t *testing.T) {
+	xterm := palette.New(nil)
+	for _, test := range []struct {
+		ch      rune
+		picture string
+	}{
+		{'A', `
+........
+........
+...#....
+..###...
+.##.##..
+##...##.
+##...##.
+#######.
+##...##.
+##...##.
+##...##.
+##...##.
+........
+........
+........
+........`},
+		{'0', `
+........
+........
+..###...
+.##.##..
+##...##.
+##...##.
+##.#.##.
+##.#.##.
+##...##.
+##...##.
+.##.##..
+..###...
+........
+........
+........
+........`},
+		{'☺', `
+........
+........
+.######.
+#......#
+#.#..#.#
+#......#
+#......#
+#.####.#
+#..##..#
+#......#
+#......#
+.######.
+........
+........
+........
+........`},
+		{'○', `
+........
+........
+........
+........
+........
+..####..
+.##..##.
+.#....#.
+.#....#.
+.##..##.
+..####..
+........
+........
+........
+........
+........`},
+	} {
+		img := render(string(test.ch), nil)
+		if g := cell(img, vt.Pt{}, xterm.Theme.Fg.RGBA()); g != picture(test.picture) {
+			t.Errorf("%q: expected\n%s\ngot\n%s", test.ch, test.picture, drawing(g))
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	xterm := palette.New(nil)
+	fg, bg := xterm.Theme.Fg.RGBA(), xterm.Theme.Bg.RGBA()
+	red, blue := xterm.Index(1).RGBA(), xterm.Index(4).RGBA()
+	img := render("A\033[31;44mB\033[0m\xe2\x95\x90\r\n\xe4\xb8\xad\xe2\x82\xac\033[1m\xe2\x9c\x93", nil)
+	if img.Bounds() != image.Rect(0, 0, 32, 32) {
+		t.Fatalf("expected a 32x32 image, got %v", img.Bounds())
+	}
+	for _, test := range []struct {
+		p      vt.Pt
+		fg, bg color.RGBA
+		glyph  [16]byte
+	}{
+		{vt.Pt{X: 0, Y: 0}, fg, bg, glyphs['A']},
+		{vt.Pt{X: 1, Y: 0}, red, blue, glyphs['B']},
+		// Double lines run to the edges of the cell.
+		{vt.Pt{X: 2, Y: 0}, fg, bg, [16]byte{5: 0xff, 7: 0xff}},
+		{vt.Pt{X: 3, Y: 0}, fg, bg, [16]byte{}},
+		// The replacement glyph straddles both cells of a wide
+		// character.
+		{vt.Pt{X: 0, Y: 1}, fg, bg, shift(replacement, 4)},
+		{vt.Pt{X: 1, Y: 1}, fg, bg, shift(replacement, -4)},
+		{vt.Pt{X: 2, Y: 1}, fg, bg, glyphs['€']},
+		{vt.Pt{X: 3, Y: 1}, fg, bg, bold(replacement)},
+	} {
+		if g := cell(img, test.p, test.fg); g != test.glyph {
+			t.Errorf("%v: expected glyph %x, got %x", test.p, test.glyph, g)
+		}
+		if g := cell(img, test.p, test.bg); g != invert(test.glyph) {
+			t.Errorf("%v: expected background %x, got %x", test.p, invert(test.glyph), g)
+		}
+	}
+}
+
+func TestRenderStyles(t *testing.T) {
+	bright := palette.New(palette.Linux)
+	bright.BoldIsBright = true
+	for _, test := range []struct {
+		input string
+		opt   *Options
+		fg    palette.RGB
+		glyph [16]byte
+	}{
+		{"\033[1;32mx", &Options{Palette: bright}, bright.Index(10), bold(glyphs['x'])},
+		{"\033[4mx", nil, palette.XTerm.Fg, underline(glyphs['x'])},
+		{"\033[7mx", nil, palette.XTerm.Bg, glyphs['x']},
+		{"\033[38;2;1;2;3mx", nil, palette.RGB{R: 1, G: 2, B: 3}, glyphs['x']},
+		{"x\033[H", &Options{Cursor: true}, palette.XTerm.Bg, glyphs['x']},
+		{"\033[4 qx\033[H", &Options{Cursor: true}, palette.XTerm.Fg,
+			cursorUnderline(glyphs['x'])},
+		{"\033[6 qx\033[H", &Options{Cursor: true}, palette.XTerm.Fg, bar(glyphs['x'])},
+		{"\033[?25lx\033[H", &Options{Cursor: true}, palette.XTerm.Fg, glyphs['x']},
+		{"x\033[H", nil, palette.XTerm.Fg, glyphs['x']},
+	} {
+		img := render(test.input, test.opt)
+		if g := cell(img, vt.Pt{}, test.fg.RGBA()); g != test.glyph {
+			t.Errorf("%q: expected glyph %x, got %x", test.input, test.glyph, g)
+		}
+	}
+}
+
+func shift(g [16]byte, n int) [16]byte {
+	for i := range g {
+		if n > 0 {
+			g[i] >>= uint(n)
+		} else {
+			g[i] <<= uint(-n)
+		}
+	}
+	return g
+}
+
+func invert(g [16]byte) [16]byte {
+	for i := range g {
+		g[i] = ^g[i]
+	}
+	return g
+}
+
+func bold(g [16]byte) [16]byte {
+	for i := range g {
+		g[i] |= g[i] >> 1
+	}
+	return g
+}
+
+func underline(g [16]byte) [16]byte {
+	g[underlineRow] = 0xff
+	return g
+}
+
+func cursorUnderline(g [16]byte) [16]byte {
+	g[14], g[15] = 0xff, 0xff
+	return g
+}
+
+func bar(g [16]byte) [16]byte {
+	for i := range g {
+		g[i] |= 0xc0
+	}
+	return g
+}
+
+// picture returns the glyph drawn in rows of "#" and ".".
+func picture(s string) (g [16]byte) {
+	for y, row := range strings.Fields(s) {
+		for x, c := range row {
+			if c == '#' {
+				g[y] |= 0x80 >> uint(x)
+			}
+		}
+	}
+	return g
+}
+
+// drawing returns g as a picture.
+func drawing(g [16]byte) string {
+	rows := make([]string, len(g))
+	for y, bits := range g {
+		row := []byte("........")
+		for x := range row {
+			if bits&(0x80>>uint(x)) != 0 {
+				row[x] = '#'
+			}
+		}
+		rows[y] = string(row)
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,183 @@
+package svg
+
+import (
+	"math"
+
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+// Line weights of box-drawing arms.
+const (
+	none = iota
+	light
+	heavy
+	double
+)
+
+// Arms, in the order of boxArms.
+const (
+	up = iota
+	right
+	down
+	left
+)
+
+// boxArms gives the lines of the box-drawing characters U+2500 to
+// U+257F, four digits a character: the weight of the arm from the
+// middle of the cell to its top, right, bottom and left edge. Dashed
+// lines are drawn solid and arcs as corners; the diagonals are all
+// zeros and are left to the font.
+const boxArms = "" +
+	"0101" + "0202" + "1010" + "2020" + "0101" + "0202" + "1010" + "2020" + // ─━│┃┄┅┆┇
+	"0101" + "0202" + "1010" + "2020" + "0110" + "0210" + "0120" + "0220" + // ┈┉┊┋┌┍┎┏
+	"0011" + "0012" + "0021" + "0022" + "1100" + "1200" + "2100" + "2200" + // ┐┑┒┓└┕┖┗
+	"1001" + "1002" + "2001" + "2002" + "1110" + "1210" + "2110" + "1120" + // ┘┙┚┛├┝┞┟
+	"2120" + "2210" + "1220" + "2220" + "1011" + "1012" + "2011" + "1021" + // ┠┡┢┣┤┥┦┧
+	"2021" + "2012" + "1022" + "2022" + "0111" + "0112" + "0211" + "0212" + // ┨┩┪┫┬┭┮┯
+	"0121" + "0122" + "0221" + "0222" + "1101" + "1102" + "1201" + "1202" + // ┰┱┲┳┴┵┶┷
+	"2101" + "2102" + "2201" + "2202" + "1111" + "1112" + "1211" + "1212" + // ┸┹┺┻┼┽┾┿
+	"2111" + "1121" + "2121" + "2112" + "2211" + "1122" + "1221" + "2212" + // ╀╁╂╃╄╅╆╇
+	"1222" + "2122" + "2221" + "2222" + "0101" + "0202" + "1010" + "2020" + // ╈╉╊╋╌╍╎╏
+	"0303" + "3030" + "0310" + "0130" + "0330" + "0013" + "0031" + "0033" + // ═║╒╓╔╕╖╗
+	"1300" + "3100" + "3300" + "1003" + "3001" + "3003" + "1310" + "3130" + // ╘╙╚╛╜╝╞╟
+	"3330" + "1013" + "3031" + "3033" + "0313" + "0131" + "0333" + "1303" + // ╠╡╢╣╤╥╦╧
+	"3101" + "3303" + "1313" + "3131" + "3333" + "0110" + "0011" + "1001" + // ╨╩╪╫╬╭╮╯
+	"1100" + "0000" + "0000" + "0000" + "0001" + "1000" + "0100" + "0010" + // ╰╱╲╳╴╵╶╷
+	"0002" + "2000" + "0200" + "0020" + "0201" + "1020" + "0102" + "2010" //   ╸╹╺╻╼╽╾╿
+
+// Quadrants of the cell, for the quadrant block elements.
+const (
+	upperLeft = 1 << iota
+	upperRight
+	lowerLeft
+	lowerRight
+)
+
+// quadrants gives the quadrant block elements U+2596 to U+259F.
+var quadrants = [...]uint8{
+	lowerLeft, lowerRight, upperLeft, upperLeft | lowerLeft | lowerRight,
+	upperLeft | lowerRight, upperLeft | upperRight | lowerLeft,
+	upperLeft | upperRight | lowerRight, upperRight, upperRight | lowerLeft,
+	upperRight | lowerLeft | lowerRight,
+}
+
+// box draws ch in the cell at x, y if it is a box-drawing or block
+// character, and reports whether it did. bg is needed to draw the
+// space between double lines.
+func (r *renderer) box(x, y float64, ch rune, fg, bg palette.RGB, blink bool) bool {
+	if ch < 0x2500 || ch > 0x259f {
+		return false
+	}
+	switch {
+	case ch < 0x2580:
+		arms := boxArms[(ch-0x2500)*4:][:4]
+		if arms == "0000" {
+			return false
+		}
+		r.lines(x, y, arms, fg, bg, blink)
+	case ch == 0x2580:
+		r.eighths(x, y, 0, 0, 8, 4, fg, blink)
+	case ch <= 0x2588:
+		n := float64(ch - 0x2580)
+		r.eighths(x, y, 0, 8-n, 8, n, fg, blink)
+	case ch <= 0x258f:
+		r.eighths(x, y, 0, 0, float64(0x2590-ch), 8, fg, blink)
+	case ch == 0x2590:
+		r.eighths(x, y, 4, 0, 4, 8, fg, blink)
+	case ch <= 0x2593:
+		r.rect(x, y, r.w, r.h, mix(bg, fg, int(ch-0x2590)), blink)
+	case ch == 0x2594:
+		r.eighths(x, y, 0, 0, 8, 1, fg, blink)
+	case ch == 0x2595:
+		r.eighths(x, y, 7, 0, 1, 8, fg, blink)
+	default:
+		q := quadrants[ch-0x2596]
+		for i := uint(0); i < 4; i++ {
+			if q&(1<<i) != 0 {
+				r.eighths(x, y, float64(i%2*4), float64(i/2*4), 4, 4, fg, blink)
+			}
+		}
+	}
+	return true
+}
+
+// eighths fills the part of the cell at x, y given in eighths of its
+// width and height.
+func (r *renderer) eighths(x, y, ex, ey, ew, eh float64, fill palette.RGB, blink bool) {
+	r.rect(x+r.w*ex/8, y+r.h*ey/8, r.w*ew/8, r.h*eh/8, fill, blink)
+}
+
+// mix returns the color n quarters of the way from a to b.
+func mix(a, b palette.RGB, n int) palette.RGB {
+	m := func(x, y uint8) uint8 { return uint8((int(x)*(4-n) + int(y)*n) / 4) }
+	return palette.RGB{R: m(a.R, b.R), G: m(a.G, b.G), B: m(a.B, b.B)}
+}
+
+// lines draws the arms of a box-drawing character. Each arm is a rect
+// from the edge of the cell to the far side of the lines across it, so
+// arms join at the middle. A double arm is a thick band with its
+// middle painted over in the background, and light and heavy arms are
+// drawn last, across any gaps.
+func (r *renderer) lines(x, y float64, arms string, fg, bg palette.RGB, blink bool) {
+	var weight [4]int
+	for i := range weight {
+		weight[i] = int(arms[i] - '0')
+	}
+	s := r.stroke()
+	// across returns the widest arm across arm i, and the widest gap
+	// between double lines across it.
+	across := func(i int) (band, gap float64) {
+		for _, j := range []int{(i + 1) % 4, (i + 3) % 4} {
+			band = math.Max(band, float64(weight[j])*s)
+			if weight[j] == double {
+				gap = s
+			}
+		}
+		return band, gap
+	}
+	for i, w := range weight {
+		if w == double {
+			band, _ := across(i)
+			r.arm(x, y, i, 3*s, band, fg, blink)
+		}
+	}
+	for i, w := range weight {
+		if w == double {
+			_, gap := across(i)
+			r.arm(x, y, i, s, gap, bg, blink)
+		}
+	}
+	for i, w := range weight {
+		if w == light || w == heavy {
+			band, _ := across(i)
+			r.arm(x, y, i, float64(w)*s, band, fg, blink)
+		}
+	}
+}
+
+// arm draws a line width wide from the middle of the cell at x, y to
+// edge i, starting from the far side of lines across it, which are
+// cross wide.
+func (r *renderer) arm(x, y float64, i int, width, cross float64, fill palette.RGB, blink bool) {
+	cx, cy := x+r.w/2, y+r.h/2
+	var x0, y0, x1, y1 float64
+	switch i {
+	case up:
+		x0, y0, x1, y1 = lo(cx, width), y, lo(cx, width)+width, lo(cy, cross)+cross
+	case right:
+		x0, y0, x1, y1 = lo(cx, cross), lo(cy, width), x+r.w, lo(cy, width)+width
+	case down:
+		x0, y0, x1, y1 = lo(cx, width), lo(cy, cross), lo(cx, width)+width, y+r.h
+	case left:
+		x0, y0, x1, y1 = x, lo(cy, width), lo(cx, cross)+cross, lo(cy, width)+width
+	}
+	if x1 > x0 && y1 > y0 {
+		r.rect(x0, y0, x1-x0, y1-y0, fill, blink)
+	}
+}
+
+// lo returns the low edge of a line width wide centered on c, rounded
+// to a whole pixel so that lines are sharp.
+func lo(c, width float64) float64 {
+	return math.Round(c - width/2)
+}
@@ -0,0 +1,214 @@
+// Package svg renders terminal snapshots as SVG images: text placed
+// character by character on a fixed grid over background rects, so
+// that the image lines up whatever font the viewer substitutes, and
+// scales without blurring.
+package svg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/greensnark/go-footv/vt"
+	"github.com/greensnark/go-footv/vt/palette"
+)
+
+// Options control rendering. The zero value renders 9x18 pixel cells
+// in a 15 pixel monospace font with xterm's colors, and no cursor.
+type Options struct {
+	// Palette supplies the colors, and decides whether bold text is
+	// drawn in bright colors.
+	Palette *palette.Palette
+
+	// FontFamily is the value of the font-family attribute, such as
+	// "DejaVu Sans Mono, monospace".
+	FontFamily string
+
+	// FontSize is the font size in pixels. It defaults to five sixths
+	// of CellHeight.
+	FontSize float64
+
+	// CellWidth and CellHeight are the size of a cell in pixels.
+	CellWidth, CellHeight int
+
+	// Cursor outlines the cursor cell, if the cursor is visible.
+	Cursor bool
+}
+
+// options returns opt with defaults filled in.
+func (opt *Options) options() Options {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	if o.Palette == nil {
+		o.Palette = palette.New(nil)
+	}
+	if o.FontFamily == "" {
+		o.FontFamily = "monospace"
+	}
+	if o.CellWidth <= 0 {
+		o.CellWidth = 9
+	}
+	if o.CellHeight <= 0 {
+		o.CellHeight = 18
+	}
+	if o.FontSize <= 0 {
+		o.FontSize = float64(o.CellHeight) * 5 / 6
+	}
+	return o
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;",
+	`"`, "&#34;", "'", "&#39;")
+
+// Render returns the screen as an SVG document, titled with the
+// terminal's title. Box-drawing and block characters are drawn as
+// rects that fill their cells rather than as text, so that lines join
+// across cells.
+func Render(s *vt.Snapshot, opt *Options) string {
+	o := opt.options()
+	r := renderer{Options: o, w: float64(o.CellWidth), h: float64(o.CellHeight)}
+	size := s.Size()
+	width, height := size.X*o.CellWidth, size.Y*o.CellHeight
+	fmt.Fprintf(&r.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s" font-size="%s">`+"\n",
+		width, height, width, height, escaper.Replace(o.FontFamily), num(o.FontSize))
+	if title := s.Title(); title != "" {
+		r.buf.WriteString("<title>" + escaper.Replace(title) + "</title>\n")
+	}
+	r.rect(0, 0, float64(width), float64(height), o.Palette.Theme.Bg, false)
+	for y := 0; y < size.Y; y++ {
+		r.background(y, s.Row(y))
+	}
+	for y := 0; y < size.Y; y++ {
+		r.foreground(y, s.Row(y))
+	}
+	if o.Cursor && s.CursorVisible() && s.Cursor().X < size.X {
+		r.cursor(s)
+	}
+	r.buf.WriteString("</svg>\n")
+	return r.buf.String()
+}
+
+type renderer struct {
+	Options
+	w, h float64
+	buf  strings.Builder
+}
+
+// num formats a coordinate or length.
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// blinking hides an element every other half second.
+const blinking = `<animate attributeName="opacity" values="1;0" dur="1s" calcMode="discrete" repeatCount="indefinite"/>`
+
+// rect draws a filled rect, blinking if blink is set.
+func (r *renderer) rect(x, y, w, h float64, fill palette.RGB, blink bool) {
+	fmt.Fprintf(&r.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"`,
+		num(x), num(y), num(w), num(h), fill.Hex())
+	if blink {
+		r.buf.WriteString(">" + blinking + "</rect>\n")
+	} else {
+		r.buf.WriteString("/>\n")
+	}
+}
+
+// background draws a rect under each run of cells on row y whose
+// background isn't the default.
+func (r *renderer) background(y int, row []vt.AttrChar) {
+	for x := 0; x < len(row); {
+		_, bg := r.Palette.Resolve(row[x].Attr)
+		run := x + 1
+		for run < len(row) {
+			if _, next := r.Palette.Resolve(row[run].Attr); next != bg {
+				break
+			}
+			run++
+		}
+		if bg != r.Palette.Theme.Bg {
+			r.rect(float64(x)*r.w, float64(y)*r.h, float64(run-x)*r.w, r.h, bg, false)
+		}
+		x = run
+	}
+}
+
+// foreground draws the text, box-drawing characters and underlines of
+// row y, a run of cells in the same style at a time.
+func (r *renderer) foreground(y int, row []vt.AttrChar) {
+	for x := 0; x < len(row); {
+		run := x + 1
+		for run < len(row) && row[run].Attr == row[x].Attr {
+			run++
+		}
+		r.run(x, y, row[x:run])
+		x = run
+	}
+}
+
+func (r *renderer) run(x0, y int, cells []vt.AttrChar) {
+	style := cells[0].Attr
+	fg, bg := r.Palette.Resolve(style)
+	blink := style.Blink()
+	top := float64(y) * r.h
+	var text strings.Builder
+	var xs []string
+	for i, c := range cells {
+		left := float64(x0+i) * r.w
+		switch {
+		case c.Ch <= ' ' || c.Ch == vt.WideContinuation:
+		case r.box(left, top, c.Ch, fg, bg, blink):
+		default:
+			text.WriteRune(c.Ch)
+			xs = append(xs, num(left))
+		}
+	}
+	if text.Len() > 0 {
+		fmt.Fprintf(&r.buf, `<text x="%s" y="%s" fill="%s"`, strings.Join(xs, " "),
+			num(top+(r.h-r.FontSize)/2+r.FontSize*0.8), fg.Hex())
+		if style.Bold() {
+			r.buf.WriteString(` font-weight="bold"`)
+		}
+		if style.Italic() {
+			r.buf.WriteString(` font-style="italic"`)
+		}
+		r.buf.WriteString(">" + escaper.Replace(text.String()))
+		if blink {
+			r.buf.WriteString(blinking)
+		}
+		r.buf.WriteString("</text>\n")
+	}
+	if style.Underline() {
+		r.rect(float64(x0)*r.w, top+r.h-2*r.stroke(), float64(len(cells))*r.w,
+			r.stroke(), fg, blink)
+	}
+}
+
+// stroke returns the width of a light line.
+func (r *renderer) stroke() float64 {
+	if r.w < 16 {
+		return 1
+	}
+	return float64(int(r.w / 8))
+}
+
+// cursor outlines the cursor cell, or both cells of a double-width
+// character under the cursor.
+func (r *renderer) cursor(s *vt.Snapshot) {
+	p := s.Cursor()
+	cell := s.Get(p)
+	if cell.Ch == vt.WideContinuation && p.X > 0 {
+		p.X--
+		cell = s.Get(p)
+	}
+	cells := 1
+	if cell.IsWide() && p.X+1 < s.Size().X {
+		cells = 2
+	}
+	fg, _ := r.Palette.Resolve(cell.Attr)
+	half := r.stroke() / 2
+	fmt.Fprintf(&r.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="none" stroke="%s" stroke-width="%s"/>`+"\n",
+		num(float64(p.X)*r.w+half), num(float64(p.Y)*r.h+half),
+		num(float64(cells)*r.w-2*half), num(r.h-2*half), fg.Hex(), num(2*half))
+}
@@ -0,0 +1,105 @@
+package svg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/greensnark/go-footv/vt"
+)
+
+func render(input string, opt *Options) string {
+	term := vt.NewSz(vt.Pt{X: 8, Y: 2})
+	term.WriteString(input)
+	return Render(term.Snapshot(), opt)
+}
+
+func TestRender(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		opt   *Options
+		want  []string
+		not   []string
+	}{
+		{"a b<", nil, []string{
+			`<svg xmlns="http://www.w3.org/2000/svg" width="72" height="36" viewBox="0 0 72 36" font-family="monospace" font-size="15">`,
+			`<rect x="0" y="0" width="72" height="36" fill="#000000"/>`,
+			`<text x="0 18 27" y="13.5" fill="#e5e5e5">ab&lt;</text>`,
+		}, []string{"<title>"}},
+		{"\033]2;t&t\007\033[1;3;41mhi\033[0m\r\n\033[7mx", &Options{
+			FontFamily: `"A" B`, CellWidth: 10, CellHeight: 20, FontSize: 10,
+		}, []string{
+			`width="80" height="40" viewBox="0 0 80 40" font-family="&#34;A&#34; B" font-size="10">`,
+			"<title>t&amp;t</title>",
+			`<rect x="0" y="0" width="20" height="20" fill="#cd0000"/>`,
+			`<text x="0 10" y="13" fill="#e5e5e5" font-weight="bold" font-style="italic">hi</text>`,
+			`<rect x="0" y="20" width="10" height="20" fill="#e5e5e5"/>`,
+			`<text x="0" y="33" fill="#000000">x</text>`,
+		}, nil},
+		{"\033[4;5;32mu", nil, []string{
+			`<text x="0" y="13.5" fill="#00cd00">u<animate attributeName="opacity"`,
+			`<rect x="0" y="16" width="9" height="1" fill="#00cd00"><animate`,
+		}, nil},
+		{"\xe4\xb8\xad\xe6\x96\x87x", nil, []string{
+			"<text x=\"0 18 36\" y=\"13.5\" fill=\"#e5e5e5\">\xe4\xb8\xad\xe6\x96\x87x</text>",
+		}, nil},
+		{"\033[1;2H", &Options{Cursor: true}, []string{
+			`<rect x="9.5" y="0.5" width="8" height="17" fill="none" stroke="#e5e5e5" stroke-width="1"/>`,
+		}, nil},
+		{"\xe4\xb8\xad\033[1;2H", &Options{Cursor: true}, []string{
+			`<rect x="0.5" y="0.5" width="17" height="17" fill="none"`,
+		}, nil},
+		{"\033[?25l", &Options{Cursor: true}, nil, []string{`fill="none"`}},
+		// Line drawing and blocks are drawn as rects, not text.
+		{"\xe2\x94\x80\xe2\x96\x88", nil, []string{
+			`<rect x="0" y="9" width="5" height="1" fill="#e5e5e5"/>`,
+			`<rect x="5" y="9" width="4" height="1" fill="#e5e5e5"/>`,
+			`<rect x="9" y="0" width="9" height="18" fill="#e5e5e5"/>`,
+		}, []string{"<text"}},
+	} {
+		svg := render(test.input, test.opt)
+		for _, want := range test.want {
+			if !strings.Contains(svg, want) {
+				t.Errorf("%q: expected %q in\n%s", test.input, want, svg)
+			}
+		}
+		for _, not := range test.not {
+			if strings.Contains(svg, not) {
+				t.Errorf("%q: unexpected %q in\n%s", test.input, not, svg)
+			}
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	r := renderer{Options: (*Options)(nil).options(), w: 8, h: 8}
+	for _, test := range []struct {
+		ch    rune
+		rects []string
+	}{
+		// ┼: arms meet over the middle.
+		{'┼', []string{"4 0 1 5", "4 4 4 1", "4 4 1 4", "0 4 5 1"}},
+		// ╔: outer and inner lines turn the corner without crossing.
+		{'╔', []string{
+			"3 3 5 3 #e5e5e5", "3 3 3 5 #e5e5e5",
+			"4 4 4 1 #000000", "4 4 1 4 #000000",
+		}},
+		// ╥: the double lines hang from the single one, which is drawn
+		// across the gap between them.
+		{'╥', []string{"3 4 3 4 #e5e5e5", "4 4 1 4 #000000", "3 4 5 1", "0 4 6 1"}},
+	} {
+		r.buf.Reset()
+		r.box(0, 0, test.ch, r.Palette.Theme.Fg, r.Palette.Theme.Bg, false)
+		var rects []string
+		for _, line := range strings.Split(strings.TrimSpace(r.buf.String()), "\n") {
+			// <rect x="0" y="0" width="1" height="1" fill="#000000"/>
+			f := strings.Fields(strings.NewReplacer(`"`, " ", "=", " ").Replace(line))
+			rects = append(rects, strings.Join([]string{f[2], f[4], f[6], f[8], f[10]}, " "))
+		}
+		all := strings.Join(rects, "\n")
+		for _, want := range test.rects {
+			if !strings.Contains(all, want) {
+				t.Errorf("%c: expected rect %q in\n%s", test.ch, want, all)
+			}
+		}
+	}
+}
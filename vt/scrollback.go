@@ -0,0 +1,35 @@
+package vt
+
+// Line is a row of cells that has scrolled off the top of the screen.
+type Line struct {
+	Cells []AttrChar
+	// Wrapped is set if the line was soft-wrapped: its text continues
+	// on the next line.
+	Wrapped bool
+}
+
+// scrollUp scrolls the scrolling region up by n lines. Lines scrolled
+// off the top of a full-screen region are saved to the scrollback.
+func (t *Tty) scrollUp(n int) {
+	if t.ScrollbackLimit > 0 && t.ScrollRange.Low == 0 &&
+		t.MarginRange.Span() == t.Size.X {
+		for y := 0; y < intMin(n, t.ScrollRange.High); y++ {
+			t.saveLine(y)
+		}
+	}
+	t.Scroll(n)
+}
+
+// saveLine appends a copy of screen row y to the scrollback.
+func (t *Tty) saveLine(y int) {
+	cells := make([]AttrChar, t.Size.X)
+	copy(cells, t.row(y))
+	t.appendScrollback(Line{Cells: cells, Wrapped: t.wrapped[y]})
+}
+
+func (t *Tty) appendScrollback(lines ...Line) {
+	t.Scrollback = append(t.Scrollback, lines...)
+	if excess := len(t.Scrollback) - t.ScrollbackLimit; excess > 0 {
+		t.Scrollback = t.Scrollback[excess:]
+	}
+}
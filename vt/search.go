@@ -0,0 +1,75 @@
+package vt
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Match is a run of text found on the screen, from the cell Start to
+// End, excluding End. A match that runs across soft-wrapped rows ends
+// on a later row than it starts, and one that ends at the right edge
+// has End.X equal to the width. Rows in the scrollback have negative
+// Y coordinates: the last line of the scrollback is -1.
+type Match struct {
+	Start, End Pt
+}
+
+// Contains reports whether the cell at p is part of the match.
+func (m Match) Contains(p Pt) bool {
+	return !before(p, m.Start) && before(p, m.End)
+}
+
+// before reports whether a comes before b in reading order.
+func before(a, b Pt) bool {
+	return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+}
+
+// SearchOptions control Search and SearchRegexp.
+type SearchOptions struct {
+	// IgnoreCase makes Search ignore case. SearchRegexp leaves it
+	// alone: compile the regexp with (?i) instead.
+	IgnoreCase bool
+	// Scrollback searches the scrollback as well as the screen.
+	Scrollback bool
+}
+
+// Search returns the places where text appears on the screen, in the
+// lines of Lines, which join soft-wrapped rows. Matches don't overlap.
+func (t *Tty) Search(text string, opt SearchOptions) []Match {
+	if text == "" {
+		return nil
+	}
+	expr := regexp.QuoteMeta(text)
+	if opt.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	return t.SearchRegexp(regexp.MustCompile(expr), opt)
+}
+
+// SearchRegexp returns the places where re matches the screen, as
+// Search. Empty matches are left out. re is used as it is, with its
+// own flags and leftmost-longest setting; opt.IgnoreCase doesn't
+// apply.
+func (t *Tty) SearchRegexp(re *regexp.Regexp, opt SearchOptions) []Match {
+	var matches []Match
+	for _, line := range t.textLines(opt.Scrollback, true) {
+		for _, m := range re.FindAllStringIndex(line.text, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			first, last := line.char(m[0]), line.char(m[1]-1)
+			end := line.cells[last]
+			end.X += line.widths[last]
+			matches = append(matches, Match{Start: line.cells[first], End: end})
+		}
+	}
+	return matches
+}
+
+// char returns the index of the character that byte offset i of the
+// line's text is in.
+func (l *textLine) char(i int) int {
+	return sort.Search(len(l.starts), func(n int) bool {
+		return l.starts[n] > i
+	}) - 1
+}
@@ -0,0 +1,76 @@
+package vt
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	term := newTextTty()
+	for _, test := range []struct {
+		text    string
+		opt     SearchOptions
+		matches []Match
+	}{
+		{"line", SearchOptions{}, []Match{{Pt{6, 0}, Pt{10, 0}}}},
+		{"across", SearchOptions{}, []Match{{Pt{8, 1}, Pt{4, 2}}}},
+		{"R", SearchOptions{}, nil},
+		{"R", SearchOptions{IgnoreCase: true}, []Match{
+			{Pt{2, 0}, Pt{3, 0}}, {Pt{1, 1}, Pt{2, 1}}, {Pt{0, 2}, Pt{1, 2}},
+			{Pt{5, 2}, Pt{6, 2}},
+		}},
+		{"文x", SearchOptions{}, []Match{{Pt{4, 3}, Pt{7, 3}}}},
+		{"中", SearchOptions{}, []Match{{Pt{2, 3}, Pt{4, 3}}}},
+		{"gone", SearchOptions{}, nil},
+		{"gone", SearchOptions{Scrollback: true}, []Match{{Pt{0, -1}, Pt{4, -1}}}},
+		{"", SearchOptions{}, nil},
+	} {
+		if matches := term.Search(test.text, test.opt); !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%q %+v: expected %v, got %v", test.text, test.opt,
+				test.matches, matches)
+		}
+	}
+}
+
+func TestSearchRegexp(t *testing.T) {
+	term := newTextTty()
+	for _, test := range []struct {
+		re      string
+		opt     SearchOptions
+		matches []Match
+	}{
+		{`^\w+`, SearchOptions{}, []Match{{Pt{0, 0}, Pt{5, 0}}, {Pt{0, 1}, Pt{7, 1}}}},
+		{`s\s+r`, SearchOptions{}, []Match{{Pt{3, 2}, Pt{6, 2}}}},
+		{`(?i)ED A`, SearchOptions{}, []Match{{Pt{5, 1}, Pt{9, 1}}}},
+		{`ED A`, SearchOptions{IgnoreCase: true}, nil},
+		{`x*`, SearchOptions{}, []Match{{Pt{6, 3}, Pt{7, 3}}}},
+	} {
+		re := regexp.MustCompile(test.re)
+		if matches := term.SearchRegexp(re, test.opt); !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%q %+v: expected %v, got %v", test.re, test.opt,
+				test.matches, matches)
+		}
+	}
+}
+
+func TestSearchRegexpLongest(t *testing.T) {
+	re := regexp.MustCompile(`(?i)WRAP|wrapped`)
+	re.Longest()
+	want := []Match{{Pt{0, 1}, Pt{7, 1}}}
+	if matches := newTextTty().SearchRegexp(re, SearchOptions{}); !reflect.DeepEqual(matches, want) {
+		t.Errorf("expected %v, got %v", want, matches)
+	}
+}
+
+func TestMatchContains(t *testing.T) {
+	m := Match{Start: Pt{8, 1}, End: Pt{4, 2}}
+	for p, want := range map[Pt]bool{
+		{7, 1}: false, {8, 1}: true, {9, 1}: true, {0, 2}: true,
+		{3, 2}: true, {4, 2}: false, {9, 0}: false,
+	} {
+		if m.Contains(p) != want {
+			t.Errorf("%v contains %v: expected %v", m, p, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package vt
+
+import "sync"
+
+// SharedTty wraps a Tty for use by one writer and many concurrent
+// readers. Writes are serialized; readers get consistent snapshots of
+// the screen taken between writes.
+type SharedTty struct {
+	mu      sync.RWMutex
+	tty     *Tty
+	version uint64 // incremented by every change to tty
+
+	// snap is the latest snapshot, of version snapVersion. Taking a
+	// snapshot updates the Tty's own bookkeeping, so it is done with
+	// mu held for writing.
+	snap        *Snapshot
+	snapVersion uint64
+}
+
+// NewSharedTty returns a SharedTty that owns t. The caller must not
+// use t directly afterwards, except through View and Update.
+func NewSharedTty(t *Tty) *SharedTty {
+	return &SharedTty{tty: t}
+}
+
+// Write writes content to the terminal.
+func (s *SharedTty) Write(content []byte) {
+	s.Update(func(t *Tty) { t.Write(content) })
+}
+
+// WriteString writes content to the terminal.
+func (s *SharedTty) WriteString(content string) {
+	s.Update(func(t *Tty) { t.WriteString(content) })
+}
+
+// Update calls fn with exclusive access to the terminal, for changes
+// other than writes, such as Resize. Callbacks set on the terminal run
+// with the same exclusive access, and must not call back into s.
+func (s *SharedTty) Update(fn func(*Tty)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.tty)
+	s.version++
+}
+
+// View calls fn with shared read access to the terminal. fn must not
+// modify the terminal or keep references to it, and should return
+// quickly, since it holds up the writer. Taking a snapshot counts as a
+// modification: use s.Snapshot instead of t.Snapshot.
+func (s *SharedTty) View(fn func(*Tty)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.tty)
+}
+
+// Version returns a counter that changes whenever the terminal does.
+func (s *SharedTty) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// Snapshot returns a snapshot of the terminal as of the last completed
+// write. Readers asking for a snapshot of the same version share one.
+func (s *SharedTty) Snapshot() *Snapshot {
+	s.mu.RLock()
+	snap, fresh := s.snap, s.snap != nil && s.snapVersion == s.version
+	s.mu.RUnlock()
+	if fresh {
+		return snap
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snap == nil || s.snapVersion != s.version {
+		s.snap = s.tty.Snapshot()
+		s.snapVersion = s.version
+	}
+	return s.snap
+}
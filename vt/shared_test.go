@@ -0,0 +1,96 @@
+package vt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	term := NewSz(Pt{10, 3})
+	term.ScrollbackLimit = 10
+	term.WriteString("one\r\ntwo\r\nthree\r\nfour\033[1;3")
+	clone := term.Clone()
+
+	term.WriteString("Hx")
+	clone.WriteString("5Hy\033[2;1Hz")
+	if text := term.TextAtN(Pt{}, 4); text != "twx " {
+		t.Errorf("original: expected \"twx \", got %#v", text)
+	}
+	if text := clone.TextAtN(Pt{}, 10); text != "two      y" {
+		t.Errorf("clone: expected \"two      y\", got %#v", text)
+	}
+	if text := clone.TextAtN(Pt{Y: 1}, 5); text != "zhree" {
+		t.Errorf("clone: expected \"zhree\", got %#v", text)
+	}
+	if len(clone.Scrollback) != 1 || len(term.Scrollback) != 1 {
+		t.Errorf("expected one scrollback line in each terminal")
+	}
+}
+
+func snapshotText(s *Snapshot, y int) string {
+	text := []rune{}
+	for _, c := range s.Row(y) {
+		text = append(text, c.Ch)
+	}
+	return string(text)
+}
+
+// TestSharedTty checks that readers always see a screen between
+// writes. Run it with -race.
+func TestSharedTty(t *testing.T) {
+	shared := NewSharedTty(NewSz(Pt{20, 2}))
+	const writes = 200
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			// Each write leaves every column of the first row equal.
+			shared.WriteString(fmt.Sprintf("\033[H%s",
+				strings.Repeat(string(rune('a'+i%26)), 20)))
+			if i%50 == 0 {
+				shared.Update(func(t *Tty) { t.Bells++ })
+			}
+		}
+	}()
+
+	errs := make(chan string, 100)
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				var row string
+				if i%2 == 0 {
+					row = snapshotText(shared.Snapshot(), 0)
+				} else {
+					shared.View(func(t *Tty) { row = t.TextAtN(Pt{}, 20) })
+				}
+				if row != strings.Repeat(row[:1], 20) {
+					errs <- row
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for row := range errs {
+		t.Errorf("inconsistent row: %#v", row)
+	}
+
+	if v := shared.Version(); v != writes+writes/50 {
+		t.Errorf("expected version %d, got %d", writes+writes/50, v)
+	}
+	snap := shared.Snapshot()
+	if shared.Snapshot() != snap {
+		t.Errorf("expected snapshots of one version to be shared")
+	}
+	shared.WriteString("x")
+	if shared.Snapshot() == snap {
+		t.Errorf("expected a new snapshot after a write")
+	}
+}
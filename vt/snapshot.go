@@ -0,0 +1,127 @@
+package vt
+
+// Modes holds the terminal modes recorded in a Snapshot.
+type Modes struct {
+	AutoWrap   bool // DECAWM
+	OriginMode bool // DECOM
+	MarginMode bool // DECLRMM
+	Kpad       bool // DECKPAM
+	UTF8       bool
+}
+
+// Snapshot is an immutable copy of the visible terminal state. Rows
+// that are unchanged between consecutive snapshots of a Tty are shared,
+// so taking one per frame costs little more than the rows that changed.
+type Snapshot struct {
+	size          Pt
+	cursor        Pt
+	cursorVisible bool
+	cursorStyle   CursorStyle
+	title         string
+	attr          Style
+	modes         Modes
+	scrollRange   Range
+	marginRange   Range
+	rows          [][]AttrChar
+	wrapped       []bool
+}
+
+// Snapshot returns an immutable copy of the screen, cursor, modes and
+// title. Rows that haven't been written since the previous snapshot,
+// including rows that have only scrolled, are shared with it rather
+// than copied, so the cost is in the rows that changed, not the
+// screen.
+//
+// Snapshot records the rows it copies in t, so it is a write, not a
+// read: it needs the same exclusive access as Write, and must not be
+// called from SharedTty.View. Use SharedTty.Snapshot instead.
+func (t *Tty) Snapshot() *Snapshot {
+	s := &Snapshot{
+		size:          t.Size,
+		cursor:        t.Cursor,
+		cursorVisible: t.CursorVisible,
+		cursorStyle:   t.CursorStyle,
+		title:         t.Title,
+		attr:          t.Attr,
+		modes: Modes{
+			AutoWrap:   t.AutoWrap,
+			OriginMode: t.OriginMode,
+			MarginMode: t.MarginMode,
+			Kpad:       t.Kpad,
+			UTF8:       t.UTF8,
+		},
+		scrollRange: t.ScrollRange,
+		marginRange: t.MarginRange,
+		rows:        make([][]AttrChar, t.Size.Y),
+		wrapped:     append([]bool(nil), t.wrapped...),
+	}
+
+	for y := range s.rows {
+		i := t.ringIndex(y)
+		if t.snapRows[i] == nil {
+			t.snapRows[i] = append([]AttrChar(nil), t.rows[i]...)
+		}
+		s.rows[y] = t.snapRows[i]
+	}
+	return s
+}
+
+func rowsEqual(a, b []AttrChar) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Size returns the screen size.
+func (s *Snapshot) Size() Pt { return s.size }
+
+// Cursor returns the cursor position. X equals the width when a wrap is
+// pending.
+func (s *Snapshot) Cursor() Pt { return s.cursor }
+
+// CursorVisible reports whether the cursor is shown (DECTCEM).
+func (s *Snapshot) CursorVisible() bool { return s.cursorVisible }
+
+// CursorStyle returns the cursor shape and blink.
+func (s *Snapshot) CursorStyle() CursorStyle { return s.cursorStyle }
+
+// Title returns the window title set by OSC 0 or 2.
+func (s *Snapshot) Title() string { return s.title }
+
+// Attr returns the style that new characters are written in.
+func (s *Snapshot) Attr() Style { return s.attr }
+
+// Modes returns the terminal modes.
+func (s *Snapshot) Modes() Modes { return s.modes }
+
+// ScrollRange returns the rows of the scrolling region.
+func (s *Snapshot) ScrollRange() Range { return s.scrollRange }
+
+// MarginRange returns the columns between the left and right margins.
+func (s *Snapshot) MarginRange() Range { return s.marginRange }
+
+// Get returns the cell at p, which must be on the screen.
+func (s *Snapshot) Get(p Pt) AttrChar { return s.rows[p.Y][p.X] }
+
+// Row returns a copy of row y.
+func (s *Snapshot) Row(y int) []AttrChar {
+	return append([]AttrChar(nil), s.rows[y]...)
+}
+
+// Wrapped reports whether row y is soft-wrapped onto the next row.
+func (s *Snapshot) Wrapped(y int) bool { return s.wrapped[y] }
+
+// RowShared reports whether row y is the same stored row as in other,
+// which is the case for unchanged rows of consecutive snapshots.
+func (s *Snapshot) RowShared(other *Snapshot, y int) bool {
+	if y >= len(s.rows) || y >= len(other.rows) || len(s.rows[y]) == 0 {
+		return false
+	}
+	return &s.rows[y][0] == &other.rows[y][0]
+}
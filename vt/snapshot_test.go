@@ -0,0 +1,55 @@
+package vt
+
+import "testing"
+
+func TestSnapshot(t *testing.T) {
+	term := NewSz(Pt{10, 3})
+	term.WriteString("\033]2;hi\007one\r\ntwo\r\nthree")
+	first := term.Snapshot()
+
+	term.WriteString("\033[2;1HTWO\033[?25l\033[4 q")
+	second := term.Snapshot()
+
+	if text := snapshotText(first, 1); text != "two       " {
+		t.Errorf("first snapshot changed: row 1 is %#v", text)
+	}
+	if text := snapshotText(second, 1); text != "TWO       " {
+		t.Errorf("expected row 1 \"TWO       \", got %#v", text)
+	}
+	for y, shared := range []bool{true, false, true} {
+		if second.RowShared(first, y) != shared {
+			t.Errorf("row %d: expected shared=%v", y, shared)
+		}
+	}
+
+	if first.Title() != "hi" || first.Cursor() != (Pt{5, 2}) ||
+		!first.CursorVisible() || first.Size() != (Pt{10, 3}) {
+		t.Errorf("unexpected first snapshot state")
+	}
+	if second.Cursor() != (Pt{3, 1}) || second.CursorVisible() ||
+		second.CursorStyle() != (CursorStyle{CursorUnderline, false}) {
+		t.Errorf("unexpected second snapshot state")
+	}
+	if !second.Modes().AutoWrap || second.Get(Pt{1, 2}).Ch != 'h' {
+		t.Errorf("unexpected second snapshot content")
+	}
+
+	row := second.Row(0)
+	row[0].Ch = 'X'
+	if second.Get(Pt{}).Ch != 'o' {
+		t.Errorf("Row did not return a copy")
+	}
+
+	term.WriteString("\033[3H\n")
+	scrolled := term.Snapshot()
+	if &scrolled.rows[0][0] != &second.rows[1][0] ||
+		snapshotText(scrolled, 0) != "TWO       " {
+		t.Errorf("scrolled row not shared with the previous snapshot")
+	}
+
+	term.Resize(Pt{12, 3})
+	if third := term.Snapshot(); third.RowShared(scrolled, 2) ||
+		third.Size() != (Pt{12, 3}) || len(third.Row(0)) != 12 {
+		t.Errorf("rows shared across a resize")
+	}
+}
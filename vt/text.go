@@ -0,0 +1,134 @@
+package vt
+
+import "strings"
+
+// appendText appends the characters of cells to b, once for each
+// double-width character.
+func appendText(b *strings.Builder, cells []AttrChar) {
+	for _, c := range cells {
+		switch {
+		case c.Ch == WideContinuation:
+		case c.Ch < ' ':
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(c.Ch)
+		}
+	}
+}
+
+// trimText returns cells without their trailing spaces, whatever their
+// attributes.
+func trimText(cells []AttrChar) []AttrChar {
+	end := len(cells)
+	for end > 0 && (cells[end-1].Ch == ' ' || cells[end-1].Ch == 0) {
+		end--
+	}
+	return cells[:end]
+}
+
+// textLine is a line of text from the screen or scrollback, with the
+// positions of its characters.
+type textLine struct {
+	text string
+	// starts are the byte offsets in text of the characters at cells,
+	// and widths their widths in cells.
+	starts []int
+	cells  []Pt
+	widths []int
+}
+
+// textLines returns the lines of Lines, recording the position of each
+// character if positions is set. Scrollback rows have negative Y
+// coordinates: the last scrollback line is -1.
+func (t *Tty) textLines(scrollback, positions bool) []textLine {
+	var lines []textLine
+	var line textLine
+	var text strings.Builder
+	addRow := func(cells []AttrChar, y int, wrapped bool) {
+		if !wrapped {
+			cells = trimText(cells)
+		}
+		if positions {
+			for x, c := range cells {
+				if c.Ch == WideContinuation {
+					continue
+				}
+				width := 1
+				if x+1 < len(cells) && cells[x+1].Ch == WideContinuation {
+					width = 2
+				}
+				line.starts = append(line.starts, text.Len())
+				line.cells = append(line.cells, Pt{X: x, Y: y})
+				line.widths = append(line.widths, width)
+				appendText(&text, cells[x:x+1])
+			}
+		} else {
+			appendText(&text, cells)
+		}
+		if wrapped {
+			return
+		}
+		line.text = text.String()
+		text.Reset()
+		lines = append(lines, line)
+		line = textLine{}
+	}
+
+	if scrollback {
+		for i, l := range t.Scrollback {
+			addRow(l.Cells, i-len(t.Scrollback), l.Wrapped)
+		}
+	}
+	for y := 0; y < t.Size.Y; y++ {
+		addRow(t.row(y), y, t.wrapped[y] && y < t.Size.Y-1)
+	}
+	return lines
+}
+
+// Lines returns the text of the screen, one string for each line, with
+// soft-wrapped rows joined into one line and trailing blanks trimmed.
+// Double-width characters appear once. If scrollback is set, the lines
+// in the scrollback come first.
+func (t *Tty) Lines(scrollback bool) []string {
+	var lines []string
+	for _, line := range t.textLines(scrollback, false) {
+		lines = append(lines, line.text)
+	}
+	return lines
+}
+
+// PlainText returns the lines of Lines, each ending in a newline,
+// without the blank lines at the end.
+func (t *Tty) PlainText(scrollback bool) string {
+	lines := t.Lines(scrollback)
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var text strings.Builder
+	for _, line := range lines {
+		text.WriteString(line)
+		text.WriteByte('\n')
+	}
+	return text.String()
+}
+
+// Text returns the text in the rectangle r of the screen, with its rows
+// trimmed of trailing blanks and separated by newlines. When r spans
+// the full width, soft-wrapped rows are joined to the next row instead.
+func (t *Tty) Text(r Rect) string {
+	r = r.Intersect(Rect{Max: t.Size})
+	fullWidth := r.Min.X == 0 && r.Max.X == t.Size.X
+	var text strings.Builder
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		cells := t.row(y)[r.Min.X:r.Max.X]
+		if fullWidth && t.wrapped[y] && y+1 < r.Max.Y {
+			appendText(&text, cells)
+			continue
+		}
+		appendText(&text, trimText(cells))
+		if y+1 < r.Max.Y {
+			text.WriteByte('\n')
+		}
+	}
+	return text.String()
+}
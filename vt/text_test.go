@@ -0,0 +1,82 @@
+package vt
+
+import (
+	"reflect"
+	"testing"
+)
+
+// textInput fills a 10x4 screen with a soft-wrapped line and a line
+// with colored blanks and double-width characters, after scrolling one
+// line into the scrollback.
+const textInput = "gone\r\n" + "first line\r\n" + "wrapped across rows\r\n" +
+	"\033[44m  \033[0m中文x\033[4H"
+
+func newTextTty() *Tty {
+	term := NewSz(Pt{10, 4})
+	term.ScrollbackLimit = 10
+	term.WriteString(textInput)
+	return term
+}
+
+func TestLines(t *testing.T) {
+	term := newTextTty()
+	want := []string{"first line", "wrapped across rows", "  中文x"}
+	if lines := term.Lines(false); !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected %q, got %q", want, lines)
+	}
+	if lines := term.Lines(true); len(lines) != 4 || lines[0] != "gone" {
+		t.Errorf("expected scrollback line first, got %q", lines)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	term := newTextTty()
+	want := "first line\nwrapped across rows\n  中文x\n"
+	if text := term.PlainText(false); text != want {
+		t.Errorf("expected %q, got %q", want, text)
+	}
+	if text := term.PlainText(true); text != "gone\n"+want {
+		t.Errorf("expected %q, got %q", "gone\n"+want, text)
+	}
+	if text := NewSz(Pt{10, 4}).PlainText(true); text != "" {
+		t.Errorf("expected no text on a blank screen, got %q", text)
+	}
+}
+
+func TestText(t *testing.T) {
+	term := newTextTty()
+	for _, test := range []struct {
+		r    Rect
+		text string
+	}{
+		{Rect{Max: Pt{10, 4}}, "first line\nwrapped across rows\n  中文x"},
+		{Rect{Min: Pt{0, 1}, Max: Pt{10, 3}}, "wrapped across rows"},
+		{Rect{Min: Pt{0, 1}, Max: Pt{10, 2}}, "wrapped ac"},
+		{Rect{Min: Pt{2, 0}, Max: Pt{7, 2}}, "rst l\napped"},
+		{Rect{Min: Pt{3, 3}, Max: Pt{10, 4}}, "文x"},
+		{Rect{Min: Pt{-5, -5}, Max: Pt{5, 1}}, "first"},
+		{Rect{Min: Pt{5, 5}, Max: Pt{8, 8}}, ""},
+	} {
+		if text := term.Text(test.r); text != test.text {
+			t.Errorf("%v: expected %q, got %q", test.r, test.text, text)
+		}
+	}
+}
+
+func TestTextAtN(t *testing.T) {
+	term := newTextTty()
+	for _, test := range []struct {
+		at     Pt
+		length int
+		text   string
+	}{
+		{Pt{0, 3}, 10, "  中文x   "},
+		{Pt{2, 3}, 3, "中文"},
+		{Pt{3, 3}, 2, "文"},
+	} {
+		if text := term.TextAtN(test.at, test.length); text != test.text {
+			t.Errorf("%v+%d: expected %q, got %q", test.at, test.length,
+				test.text, text)
+		}
+	}
+}
@@ -3,19 +3,14 @@
 package vt
 
 import (
-	"bytes"
-	"fmt"
-	"os"
-	"strconv"
+	"strings"
 
 	"github.com/greensnark/go-footv/cset"
 	"github.com/greensnark/go-footv/unicode"
 )
 
-type Attribute uint32
-
 type AttrChar struct {
-	Attr Attribute
+	Attr Style
 	Ch   rune
 }
 
@@ -25,24 +20,84 @@ type Tty struct {
 	Size          Pt // aka sx, sy in termrec
 	Cursor        Pt // aka cx, cy
 	CursorVisible bool
-	Attr          Attribute // aka attr
-	ScrollRange   Range     // aka s1, s2
+	CursorStyle   CursorStyle
+	Bells         int    // number of BELs received
+	Title         string // set by OSC 0 and 2
+	Attr          Style  // aka attr
+	ScrollRange   Range  // aka s1, s2
+	MarginRange   Range  // left and right margins, DECSLRM
+
+	Debug     bool   // report diagnostics on stderr
+	State     VTMode // aka state
+	Resizable bool   // aka opt_allow_resize
+	AutoWrap  bool   // aka opt_auto_wrap
+	Kpad      bool   // aka opt_kpad
+	UTF8      bool   // aka utf
+
+	// Reflow makes Resize rewrap soft-wrapped lines to the new width
+	// instead of truncating them.
+	Reflow bool
+	// ResetSize makes a full reset (RIS) restore the size the terminal
+	// was created with.
+	ResetSize bool
+	// ScrollbackLimit is the number of lines scrolled off the top of
+	// the screen to keep in Scrollback. Zero disables scrollback.
+	ScrollbackLimit int
+	Scrollback      []Line
 
-	Debug     bool
-	State     VTMode     // aka state
-	Buf       []AttrChar // aka scr
-	Resizable bool       // aka opt_allow_resize
-	AutoWrap  bool       // aka opt_auto_wrap
-	Kpad      bool       // aka opt_kpad
-	UTF8      bool       // aka utf
+	// MarginMode enables left and right margins (DECLRMM); while it
+	// is unset MarginRange always spans the full width.
+	MarginMode bool
+	// OriginMode makes cursor addressing relative to the scrolling
+	// region and margins (DECOM).
+	OriginMode bool
 
 	csetSelect  int  // aka G
 	csetShift   uint // aka curG in termrec
 	utfChar     rune // aka utf_char
 	utfCount    int  // aka utf_count
 	savedCursor Pt   // aka save_cx, save_cy
-	stateProc   func(byte)
-	stateTok    []byte
+	lastChar    rune // aka prev_char
+	stateTok    []int
+	stateInter  byte // CSI intermediate byte, if any
+	statePriv   byte // CSI private marker other than '?', if any
+
+	stateOverflow bool // too many CSI parameters
+
+	// Observer, if set, receives diagnostics about unsupported and
+	// malformed input.
+	Observer DiagnosticObserver
+	// Frame is the index of the frame being played, maintained by
+	// whatever drives the Tty (such as player.Player), or -1. It is
+	// only used to label diagnostics.
+	Frame int
+
+	offset   int64  // bytes written so far
+	seqBuf   []byte // the current escape sequence, for diagnostics
+	seqStart int64  // offset of seqBuf[0]
+	utfStart int64  // offset of the current UTF-8 lead byte
+
+	// marginWrap is set when a character was written in the last
+	// column inside the right margin, and the next one should wrap to
+	// the left margin. marginWrapAt is where the cursor was left.
+	marginWrap   bool
+	marginWrapAt Pt
+
+	wrapped     []bool // rows soft-wrapped onto the next row
+	tabStops    []bool // aka tabs
+	oscBuf      []byte
+	initialSize Pt
+
+	// rows holds the screen, one slice per row, as a ring starting at
+	// screen row 0 at index top, so that scrolling moves rows rather
+	// than cells, and scrolling the whole screen just moves top.
+	// snapRows holds, for each entry in rows, its copy in the last
+	// snapshot, to share with the next one, or nil if the row has been
+	// written since. Rows written through editRow and editSpans are
+	// marked this way.
+	rows     [][]AttrChar // aka scr
+	snapRows [][]AttrChar
+	top      int
 
 	CursorMoved func(*Tty, Pt)
 	CharWritten func(*Tty, Pt, AttrChar)
@@ -50,16 +105,19 @@ type Tty struct {
 	Scrolled    func(*Tty, int)
 	Resized     func(tty *Tty, oldSz, newSz Pt)
 	Flushed     func(*Tty)
+	Bell        func(*Tty)
 }
 
 func defaultTtySize() Pt { return Pt{X: 80, Y: 24} }
 
 func NewSz(size Pt) *Tty {
 	tty := &Tty{
-		Size:       size,
-		UTF8:       true,
-		csetSelect: 1 << 1,
-		stateTok:   make([]byte, 1, 10),
+		Size:        size,
+		UTF8:        true,
+		csetSelect:  1 << 1,
+		stateTok:    make([]int, 1, 10),
+		initialSize: size,
+		Frame:       -1,
 	}
 	tty.init()
 	return tty
@@ -73,16 +131,63 @@ func (t *Tty) InDECCset() bool {
 	return (t.csetSelect & (1 << t.csetShift)) != 0
 }
 
-func (t *Tty) bufSize() int              { return t.Size.Area() }
-func (t *Tty) allocBuf(sz Pt) []AttrChar { return make([]AttrChar, sz.Area()) }
+func (t *Tty) bufSize() int { return t.Size.Area() }
+
+// allocScreen allocates blank rows for the current size, sharing one
+// backing array.
+func (t *Tty) allocScreen() {
+	sz := t.Size
+	cells := make([]AttrChar, sz.Area())
+	t.rows = make([][]AttrChar, sz.Y)
+	for y := range t.rows {
+		t.rows[y] = cells[y*sz.X : (y+1)*sz.X : (y+1)*sz.X]
+	}
+	t.snapRows = make([][]AttrChar, sz.Y)
+	t.top = 0
+}
+
+// ringIndex returns the index in rows of screen row y.
+func (t *Tty) ringIndex(y int) int {
+	i := t.top + y
+	if i >= len(t.rows) {
+		i -= len(t.rows)
+	}
+	return i
+}
+
+// row returns screen row y, for reading.
+func (t *Tty) row(y int) []AttrChar {
+	return t.rows[t.ringIndex(y)]
+}
+
+// editRow returns screen row y, for writing, marking it changed since
+// the last snapshot.
+func (t *Tty) editRow(y int) []AttrChar {
+	i := t.ringIndex(y)
+	t.snapRows[i] = nil
+	return t.rows[i]
+}
+
+// screenRows returns the screen rows in order from the top.
+func (t *Tty) screenRows() [][]AttrChar {
+	rows := make([][]AttrChar, t.Size.Y)
+	for y := range rows {
+		rows[y] = t.row(y)
+	}
+	return rows
+}
 
 func (t *Tty) init() {
-	t.Buf = t.allocBuf(t.Size)
+	t.allocScreen()
+	t.wrapped = make([]bool, t.Size.Y)
+	t.tabStops = make([]bool, t.Size.X)
 	t.Reset()
 }
 
+// DefaultAttrChar returns the blank character used to erase cells:
+// a space in the current attributes, minus any DECSCA protection.
 func (t *Tty) DefaultAttrChar() AttrChar {
-	return AttrChar{Attr: t.Attr, Ch: ' '}
+	return AttrChar{Attr: t.Attr.Without(VT100AttrProtected), Ch: ' '}
 }
 
 func abs(n int) int {
@@ -92,39 +197,118 @@ func abs(n int) int {
 	return n
 }
 
+// Scroll scrolls the scrolling region up by scrolledLines, or down if
+// scrolledLines is negative. If left and right margins are set, only
+// the columns between them move.
 func (t *Tty) Scroll(scrolledLines int) {
+	if t.MarginRange.Span() < t.Size.X {
+		t.scrollMargins(scrolledLines)
+		return
+	}
+
 	scrollRegionSize := t.ScrollRange.Span()
 	absScrolledLines := abs(scrolledLines)
 
+	low, high := t.ScrollRange.Low, t.ScrollRange.High
 	preservedLines := scrollRegionSize - absScrolledLines
 	if preservedLines <= 0 {
-		t.ClearRegion(t.ScrollRange.Low*t.Size.X, scrollRegionSize*t.Size.X)
+		t.clearRows(low, high)
 		return
 	}
 
-	preservedCharacters := preservedLines * t.Size.X
+	t.rotateRows(low, high, scrolledLines)
 	if scrolledLines < 0 {
-		targetOffset := t.posOffset(Pt{0, t.ScrollRange.Low - scrolledLines})
-		sourceOffset := t.posOffset(Pt{0, t.ScrollRange.Low})
-		copy(t.Buf[targetOffset:preservedCharacters+targetOffset],
-			t.Buf[sourceOffset:preservedCharacters+sourceOffset])
-		t.ClearRegion(t.posOffset(Pt{0, t.ScrollRange.Low}),
-			absScrolledLines*t.Size.X)
+		t.clearRows(low, low+absScrolledLines)
 	} else {
-		targetOffset := t.posOffset(Pt{0, t.ScrollRange.Low})
-		sourceOffset := t.posOffset(Pt{0, t.ScrollRange.Low + scrolledLines})
-		copy(t.Buf[targetOffset:targetOffset+preservedCharacters],
-			t.Buf[sourceOffset:sourceOffset+preservedCharacters])
-		t.ClearRegion(t.posOffset(Pt{0, t.ScrollRange.High - scrolledLines}),
-			scrolledLines*t.Size.X)
+		t.clearRows(high-absScrolledLines, high)
 	}
 }
 
-func (t *Tty) consumeByte(b byte) {
-	if t.anyStateConsume(b) {
+// rotateRows rotates rows low to high-1 up by n, or down if n is
+// negative, so that the rows scrolled off one end reappear at the
+// other, ready to be cleared. Only the row slices move, not the cells,
+// and rotating the whole screen only moves the start of the ring.
+func (t *Tty) rotateRows(low, high, n int) {
+	span := high - low
+	n = (n%span + span) % span
+	if n == 0 {
+		return
+	}
+	reverse := func(i, j int, swap func(i, j int)) {
+		for j--; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	rotate := func(swap func(i, j int)) {
+		reverse(low, low+n, swap)
+		reverse(low+n, high, swap)
+		reverse(low, high, swap)
+	}
+
+	rotate(func(i, j int) {
+		t.wrapped[i], t.wrapped[j] = t.wrapped[j], t.wrapped[i]
+	})
+	if span == len(t.rows) {
+		t.top = t.ringIndex(n)
 		return
 	}
-	t.stateProc(b)
+	rotate(func(i, j int) {
+		i, j = t.ringIndex(i), t.ringIndex(j)
+		t.rows[i], t.rows[j] = t.rows[j], t.rows[i]
+		t.snapRows[i], t.snapRows[j] = t.snapRows[j], t.snapRows[i]
+	})
+}
+
+// clearRows erases rows low to high-1 and their wrapped flags.
+func (t *Tty) clearRows(low, high int) {
+	zero := t.DefaultAttrChar()
+	for y := low; y < high; y++ {
+		row := t.editRow(y)
+		for x := range row {
+			row[x] = zero
+		}
+	}
+	t.clearWrapped(low, high)
+}
+
+func (t *Tty) scrollMargins(scrolledLines int) {
+	left, width := t.MarginRange.Low, t.MarginRange.Span()
+	low, high := t.ScrollRange.Low, t.ScrollRange.High
+	n := intMin(abs(scrolledLines), t.ScrollRange.Span())
+	row := func(y int) []AttrChar {
+		return t.editRow(y)[left : left+width]
+	}
+	if scrolledLines < 0 {
+		for y := high - 1; y >= low+n; y-- {
+			copy(row(y), row(y-n))
+		}
+		for y := low; y < low+n; y++ {
+			t.ClearRegion(t.posOffset(Pt{X: left, Y: y}), width)
+		}
+	} else {
+		for y := low; y < high-n; y++ {
+			copy(row(y), row(y+n))
+		}
+		for y := high - n; y < high; y++ {
+			t.ClearRegion(t.posOffset(Pt{X: left, Y: y}), width)
+		}
+	}
+	for y := low; y < high; y++ {
+		repairWideRow(t.editRow(y))
+	}
+}
+
+func (t *Tty) consumeByte(b byte) {
+	t.recordSeqByte(b)
+	if !t.anyStateConsume(b) {
+		t.consumeState(b)
+	}
+	t.offset++
+}
+
+// Offset returns the number of bytes written to the Tty.
+func (t *Tty) Offset() int64 {
+	return t.offset
 }
 
 // anyStateConsume attempts to consume a single byte, and returns true
@@ -135,6 +319,8 @@ func (t *Tty) anyStateConsume(b byte) bool {
 	case 7:
 		if t.State == VTOsc {
 			t.changeState(VTNorm)
+		} else {
+			t.bell()
 		}
 	case 8:
 		t.backspace()
@@ -164,35 +350,37 @@ func (t *Tty) anyStateConsume(b byte) bool {
 }
 
 func (t *Tty) changeState(newState VTMode) {
-	if t.State == VTGetPars {
+	switch t.State {
+	case VTGetPars:
 		t.clearParState()
+	case VTOsc:
+		t.finishOsc()
 	}
 	t.State = newState
-	t.stateProc = t.currentStateProc(t.State)
 }
 
-func (t *Tty) currentStateProc(state VTMode) func(byte) {
+// consumeState passes b to the handler for the current state.
+func (t *Tty) consumeState(b byte) {
 	switch t.State {
-	case VTNorm:
-		return t.consumeNorm
 	case VTEsc:
-		return t.consumeEsc
+		t.consumeEsc(b)
 	case VTSquare:
-		return t.consumeEscSquare
+		t.consumeEscSquare(b)
 	case VTPercent:
-		return t.consumeEscPercent
+		t.consumeEscPercent(b)
 	case VTGetPars:
-		return t.consumeEscGetPars
+		t.consumeEscGetPars(b)
 	case VTQues:
-		return t.consumeEscQues
+		t.consumeEscQues(b)
 	case VTSetG0:
-		return t.consumeSetG0
+		t.consumeSetG0(b)
 	case VTSetG1:
-		return t.consumeSetG1
+		t.consumeSetG1(b)
 	case VTOsc:
-		return t.consumeOsc
+		t.consumeOsc(b)
+	default:
+		t.consumeNorm(b)
 	}
-	return t.consumeNorm
 }
 
 func (t *Tty) consumeNorm(b byte) {
@@ -212,6 +400,8 @@ func (t *Tty) consumeUTF8(b byte) {
 				t.applyRune(unicode.NormalizeMultibyte(t.utfChar))
 			}
 		} else {
+			t.badUTF8()
+			t.utfStart = t.offset
 			set := func(c int, ch byte) {
 				t.utfCount = c
 				t.utfChar = rune(ch)
@@ -228,14 +418,24 @@ func (t *Tty) consumeUTF8(b byte) {
 				set(5, b&0x1)
 			} else {
 				set(0, 0)
+				t.report(DiagnosticMalformed, "UTF-8 bad byte",
+					[]byte{b}, t.offset)
 			}
 		}
 	} else {
+		t.badUTF8()
 		t.utfCount = 0
 		t.applyRune(rune(b))
 	}
 }
 
+// badUTF8 reports a truncated UTF-8 character, if one is pending.
+func (t *Tty) badUTF8() {
+	if t.utfCount > 0 {
+		t.report(DiagnosticMalformed, "UTF-8 truncated", nil, t.utfStart)
+	}
+}
+
 func (t *Tty) consumeCp437(b byte) {
 	t.applyRune(cset.Cp437[b])
 }
@@ -248,13 +448,77 @@ func (t *Tty) applyRune(c rune) {
 		if c < 128 && t.InDECCset() {
 			c = cset.VT100[c]
 		}
+		t.putChar(c)
+	}
+}
+
+// printableRun returns the length of the run of printable ASCII at the
+// start of content.
+func printableRun(content []byte) int {
+	for i, b := range content {
+		if b < ' ' || b > '~' {
+			return i
+		}
+	}
+	return len(content)
+}
+
+// writePrintable writes the run of printable ASCII at the start of
+// content straight into the screen rows, and returns its length. It
+// must only be called in VTNorm with no UTF-8 character pending, and
+// has the same effect as passing each byte to consumeByte: these bytes
+// are the same in UTF-8 and CP437, and only the DEC graphics charset
+// translates them, in which case it writes nothing.
+func (t *Tty) writePrintable(content []byte) int {
+	n := printableRun(content)
+	if n == 0 || t.InDECCset() {
+		return 0
+	}
+	for run := content[:n]; len(run) > 0; {
 		t.clampCursorX()
-		t.Buf[t.posOffset(t.Cursor)] = AttrChar{
-			Attr: t.Attr,
-			Ch:   c,
+		end := t.Size.X
+		if t.Cursor.X < t.MarginRange.High {
+			end = t.MarginRange.High
+		}
+		full := t.editRow(t.Cursor.Y)
+		row := full[t.Cursor.X:end]
+		count := intMin(len(run), len(row))
+		for i, b := range run[:count] {
+			row[i] = AttrChar{Attr: t.Attr, Ch: rune(b)}
+		}
+		repairWide(full, t.Cursor.X, t.Cursor.X+count)
+		run = run[count:]
+		t.Cursor.X += count
+		if t.Cursor.X == t.MarginRange.High && t.Cursor.X < t.Size.X {
+			t.marginWrap = true
+			t.marginWrapAt = t.Cursor
 		}
-		t.Cursor.X++
 	}
+	t.lastChar = rune(content[n-1])
+	t.offset += int64(n)
+	return n
+}
+
+// putChar writes c at the cursor and advances it, wrapping first if
+// the previous character filled the line.
+func (t *Tty) putChar(c rune) {
+	if c > 0x7f && unicode.IsWide(c) {
+		t.putWide(c)
+		return
+	}
+	t.clampCursorX()
+	row := t.editRow(t.Cursor.Y)
+	row[t.Cursor.X] = AttrChar{
+		Attr: t.Attr,
+		Ch:   c,
+	}
+	repairWide(row, t.Cursor.X, t.Cursor.X+1)
+	t.Cursor.X++
+	if t.Cursor.X == t.MarginRange.High && t.Cursor.X < t.Size.X {
+		t.marginWrap = true
+		t.marginWrapAt = t.Cursor
+	}
+	t.lastChar = c
 }
 
 func (t *Tty) consumeSetG0(b byte) {
@@ -273,6 +537,8 @@ func (t *Tty) setCharset(g uint, b byte) {
 		t.csetSelect |= 1 << g
 	case 'B', 'U':
 		t.csetSelect &= ^(1 << g)
+	default:
+		t.unsupported("ESC %c %c", "()"[g], b)
 	}
 }
 
@@ -295,6 +561,16 @@ func (t *Tty) consumeEsc(b byte) {
 	case '8':
 		t.Cursor = t.savedCursor
 		t.changeState(VTNorm)
+	case 'c': // full reset
+		t.changeState(VTNorm)
+		t.Reset()
+	case 'H': // set tab stop
+		t.changeState(VTNorm)
+		if t.Cursor.X < t.Size.X {
+			t.tabStops[t.Cursor.X] = true
+		}
+	case '\\': // string terminator, ending OSC
+		t.changeState(VTNorm)
 	case 'D':
 		t.changeState(VTNorm)
 		t.verticaltab()
@@ -311,12 +587,38 @@ func (t *Tty) consumeEsc(b byte) {
 		t.changeState(VTNorm)
 		t.Kpad = false
 	default:
-		t.err(b)
+		t.unsupported("ESC %c", b)
+		t.changeState(VTNorm)
 	}
 }
 
-// Just wait for OSC clear
+// maxOscLen is the longest OSC string we keep; the rest is dropped.
+const maxOscLen = 512
+
+// Collect the OSC string until it ends with BEL or ST.
 func (t *Tty) consumeOsc(b byte) {
+	if len(t.oscBuf) < maxOscLen {
+		t.oscBuf = append(t.oscBuf, b)
+	}
+}
+
+// finishOsc applies the OSC string collected so far.
+func (t *Tty) finishOsc() {
+	osc := string(t.oscBuf)
+	t.oscBuf = t.oscBuf[:0]
+	sep := strings.IndexByte(osc, ';')
+	if sep < 0 {
+		t.report(DiagnosticMalformed, "OSC", []byte("\033]"+osc), t.seqStart)
+		return
+	}
+	switch osc[:sep] {
+	case "0", "2": // icon name and title, title
+		t.Title = osc[sep+1:]
+	case "1": // icon name
+	default:
+		t.report(DiagnosticUnsupported, "OSC "+osc[:sep],
+			[]byte("\033]"+osc), t.seqStart)
+	}
 }
 
 func (t *Tty) consumeEscPercent(b byte) {
@@ -325,35 +627,54 @@ func (t *Tty) consumeEscPercent(b byte) {
 		t.UTF8 = false
 	case '8', 'G':
 		t.UTF8 = true
+	default:
+		t.unsupported("ESC %% %c", b)
 	}
 	t.changeState(VTNorm)
 }
 
 func (t *Tty) consumeEscSquare(b byte) {
-	if b == '?' {
+	switch b {
+	case '?':
 		t.changeState(VTQues)
 		return
+	case '<', '=', '>':
+		// Private parameters we don't support; parse and discard.
+		t.changeState(VTGetPars)
+		t.statePriv = b
+		return
 	}
 	t.changeState(VTGetPars)
-	t.consumeByte(b)
+	t.consumeState(b)
 }
 
-func minMove(b byte, min int) int {
-	if int(b) < min {
+func minMove(n int, min int) int {
+	if n < min {
 		return min
 	}
-	return int(b)
+	return n
 }
 
+// maxParValue is the largest value a numeric CSI parameter can
+// take. Larger values saturate instead of overflowing.
+const maxParValue = 65535
+
 func (t *Tty) applyParameterByte(b byte) bool {
+	if t.stateOverflow && (b == ';' || (b >= '0' && b <= '9')) {
+		return true
+	}
 	switch b {
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		i := len(t.stateTok) - 1
-		t.stateTok[i] = t.stateTok[i]*10 + (b - '0')
+		t.stateTok[i] = t.stateTok[i]*10 + int(b-'0')
+		if t.stateTok[i] > maxParValue {
+			t.stateTok[i] = maxParValue
+		}
 		return true
 	case ';':
 		if t.isStateFull() {
-			t.err(b)
+			t.malformed("CSI too many parameters")
+			t.stateOverflow = true
 			return true
 		}
 		t.stateTok = append(t.stateTok, 0)
@@ -362,79 +683,145 @@ func (t *Tty) applyParameterByte(b byte) bool {
 	return false
 }
 
+// isIntermediateByte returns true if b is a CSI intermediate byte,
+// such as the '"' in DECSCA.
+func isIntermediateByte(b byte) bool {
+	return b >= 0x20 && b <= 0x2f
+}
+
 func (t *Tty) consumeEscGetPars(b byte) {
 	if t.applyParameterByte(b) {
 		return
 	}
+	if isIntermediateByte(b) {
+		t.stateInter = b
+		return
+	}
+	if t.statePriv != 0 {
+		t.unsupportedCSI(b)
+		t.changeState(VTNorm)
+		return
+	}
+	if t.stateOverflow {
+		t.changeState(VTNorm)
+		return
+	}
+	if t.stateInter != 0 {
+		t.consumeEscGetParsInter(b)
+		t.changeState(VTNorm)
+		return
+	}
 	switch b {
 	case 'm':
 		t.applyParAttrs(t.stateTok)
 	case 'D':
 		t.cursorMove(Pt{X: -minMove(t.stateTok[0], 1)})
-	case 'C', 'a':
+	case 'C', 'a': // CUF, HPR
 		t.cursorMove(Pt{X: minMove(t.stateTok[0], 1)})
 	case 'A':
 		t.cursorMove(Pt{Y: -minMove(t.stateTok[0], 1)})
-	case 'B':
+	case 'B', 'e': // CUD, VPR
+		t.cursorMove(Pt{Y: minMove(t.stateTok[0], 1)})
+	case 'E': // cursor to start of next line
 		t.cursorMove(Pt{Y: minMove(t.stateTok[0], 1)})
+		t.carriageReturn()
+	case 'F': // cursor to start of previous line
+		t.cursorMove(Pt{Y: -minMove(t.stateTok[0], 1)})
+		t.carriageReturn()
 	case 'r': // set scrolling region
 		scrollMin := minMove(t.stateTok[0], 1)
 		scrollMax := t.Size.Y
 		if len(t.stateTok) > 1 && t.stateTok[1] > 0 {
-			scrollMax = int(t.stateTok[1])
+			scrollMax = t.stateTok[1]
 		}
 		if scrollMax <= t.Size.Y && scrollMin < scrollMax {
-			t.ScrollRange = Range{Low: scrollMin, High: scrollMax}
-			t.Cursor = Pt{Y: scrollMin}
+			t.ScrollRange = Range{Low: scrollMin - 1, High: scrollMax}
+			t.cursorHome()
 		}
-	case 'J': // clear screen
-		switch t.stateTok[0] {
-		case 0: // from cursor
-			offset := t.posOffset(t.Cursor)
-			t.ClearRegion(offset, t.maxOffset()-offset)
-		case 1: // to cursor
-			t.ClearRegion(0, t.posOffset(t.Cursor))
-		case 2: // full screen
-			t.ClearRegion(0, t.maxOffset())
+	case 's':
+		if !t.MarginMode { // save cursor, like ESC 7
+			t.savedCursor = t.Cursor
+			break
 		}
-	case 'K': // clear line
-		switch t.stateTok[0] {
-		case 0: // from cursor
-			t.ClearRegion(t.posOffset(t.Cursor), t.Size.X-t.Cursor.X)
-		case 1: // to cursor
-			t.ClearRegion(t.posOffset(Pt{Y: t.Cursor.Y}), t.Cursor.X)
-		case 2:
-			t.ClearRegion(t.posOffset(Pt{Y: t.Cursor.Y}), t.Size.X)
+		marginMin := minMove(t.stateTok[0], 1)
+		marginMax := t.Size.X
+		if len(t.stateTok) > 1 && t.stateTok[1] > 0 {
+			marginMax = t.stateTok[1]
+		}
+		if marginMax <= t.Size.X && marginMin < marginMax {
+			t.MarginRange = Range{Low: marginMin - 1, High: marginMax}
+			t.cursorHome()
 		}
+	case 'u': // restore cursor, like ESC 8
+		t.Cursor = t.savedCursor
+	case 'S': // scroll up
+		t.scrollUp(clamp(t.stateTok[0], 1, t.Size.Y))
+	case 'T': // scroll down; with more parameters, mouse tracking
+		if len(t.stateTok) == 1 {
+			t.Scroll(-clamp(t.stateTok[0], 1, t.Size.Y))
+		}
+	case 'J': // clear screen
+		t.eraseDisplay(t.stateTok[0], false)
+	case 'K': // clear line
+		t.eraseLine(t.stateTok[0], false)
 	case 'L': // insert line
-		if t.InScrollingRegion() {
+		if t.InScrollingRegion() && t.InMargins() {
 			t.scrollExcursion(func() {
 				t.Scroll(-minMove(t.stateTok[0], 1))
 			})
+			t.Cursor.X = t.MarginRange.Low
 		}
 	case 'M': // delete line
-		if t.InScrollingRegion() {
+		if t.InScrollingRegion() && t.InMargins() {
 			t.scrollExcursion(func() {
 				t.Scroll(minMove(t.stateTok[0], 1))
 			})
+			t.Cursor.X = t.MarginRange.Low
 		}
+	case '@': // insert blank characters
+		t.insertChars(minMove(t.stateTok[0], 1))
+	case 'P': // delete characters
+		t.deleteChars(minMove(t.stateTok[0], 1))
 	case 'X': // erase to the right
 		eraseSize := minMove(t.stateTok[0], 1)
 		if eraseSize+t.Cursor.X > t.Size.X {
 			eraseSize = t.Size.X - t.Cursor.X
 		}
 		t.ClearRegion(t.posOffset(t.Cursor), eraseSize)
+	case 'b': // repeat the last character written
+		if t.lastChar != 0 {
+			count := clamp(t.stateTok[0], 1, t.Size.Area())
+			for i := 0; i < count; i++ {
+				t.putChar(t.lastChar)
+			}
+		}
+	case 'I': // forward tab
+		for i := clamp(t.stateTok[0], 1, t.Size.X); i > 0; i-- {
+			t.tab()
+		}
+	case 'Z': // backward tab
+		for i := clamp(t.stateTok[0], 1, t.Size.X); i > 0; i-- {
+			t.backTab()
+		}
 	case 'f', 'H': // move cursor
-		t.Cursor = t.clampCursorStrict(Pt{
-			X: t.stateN(1) - 1,
-			Y: t.stateN(0) - 1,
-		})
+		t.setCursorY(t.stateN(0) - 1)
+		t.setCursorX(t.stateN(1) - 1)
 	case 'G', '`': // move cursor horizontally
-		t.Cursor.X = clamp(int(t.stateTok[0])-1, 0, t.Size.X-1)
-	case 'd':
-		t.Cursor.Y = clamp(int(t.stateTok[0])-1, 0, t.Size.Y-1)
-	case 'c': // power on defaults
-		t.Reset()
+		t.setCursorX(minMove(t.stateTok[0], 1) - 1)
+	case 'd': // move cursor vertically
+		t.setCursorY(minMove(t.stateTok[0], 1) - 1)
+	case 'c': // device attributes: we have no one to answer
+	case 'g': // clear tab stops
+		switch t.stateTok[0] {
+		case 0:
+			if t.Cursor.X < t.Size.X {
+				t.tabStops[t.Cursor.X] = false
+			}
+		case 3:
+			for x := range t.tabStops {
+				t.tabStops[x] = false
+			}
+		}
 	case 't':
 		switch t.stateTok[0] {
 		case 8: // \e[8;<h>;<w>t -> resize window
@@ -442,23 +829,68 @@ func (t *Tty) consumeEscGetPars(b byte) {
 				break
 			}
 			t.Resize(Pt{
-				X: t.stateNDef(2, t.Size.X),
-				Y: t.stateNDef(1, t.Size.Y),
+				X: resizeDim(t.stateNDef(2, 0), t.Size.X),
+				Y: resizeDim(t.stateNDef(1, 0), t.Size.Y),
 			})
+		default:
+			t.unsupported("CSI %d t", t.stateTok[0])
+		}
+	case 'h', 'l': // ANSI modes, none of which we support
+		for _, mode := range t.stateTok {
+			t.unsupported("CSI %d %c", mode, b)
 		}
+	default:
+		t.unsupportedCSI(b)
 	}
 	t.changeState(VTNorm)
 }
 
+// consumeEscGetParsInter handles the final byte of a CSI sequence
+// that has an intermediate byte.
+func (t *Tty) consumeEscGetParsInter(b byte) {
+	switch {
+	case t.stateInter == ' ' && b == 'q': // DECSCUSR: cursor style
+		t.setCursorStyle(t.stateTok[0])
+	case t.stateInter == '!' && b == 'p': // DECSTR: soft reset
+		t.SoftReset()
+	case t.stateInter == '"' && b == 'q': // DECSCA: protect characters
+		switch t.stateTok[0] {
+		case 1:
+			t.Attr = t.Attr.With(VT100AttrProtected)
+		case 0, 2:
+			t.Attr = t.Attr.Without(VT100AttrProtected)
+		}
+	default:
+		t.unsupportedCSI(b)
+	}
+}
+
 func (t *Tty) consumeEscQues(b byte) {
 	if t.applyParameterByte(b) {
 		return
 	}
+	if isIntermediateByte(b) {
+		t.stateInter = b
+		return
+	}
+	if t.stateInter != 0 || t.stateOverflow {
+		if !t.stateOverflow {
+			t.unsupportedCSI(b)
+		}
+		t.changeState(VTNorm)
+		return
+	}
 	switch b {
 	case 'h': // set options
 		t.applyParOptions(t.stateTok, true)
 	case 'l': // unset options
 		t.applyParOptions(t.stateTok, false)
+	case 'J': // selective erase in display
+		t.eraseDisplay(t.stateTok[0], true)
+	case 'K': // selective erase in line
+		t.eraseLine(t.stateTok[0], true)
+	default:
+		t.unsupportedCSI(b)
 	}
 	t.changeState(VTNorm)
 }
@@ -467,6 +899,21 @@ func (t *Tty) stateN(index int) int {
 	return t.stateNDef(index, 0)
 }
 
+// maxResizeDim is the largest width or height the input can resize
+// the terminal to, so that a stray escape sequence can't allocate
+// gigabytes.
+const maxResizeDim = 1000
+
+// resizeDim returns the width or height asked for by a resize
+// sequence: the current one if n is zero or missing, as in xterm, and
+// at most maxResizeDim.
+func resizeDim(n, current int) int {
+	if n == 0 {
+		return current
+	}
+	return intMin(n, maxResizeDim)
+}
+
 func (t *Tty) stateNDef(index, defval int) int {
 	if index < len(t.stateTok) {
 		return int(t.stateTok[index])
@@ -485,97 +932,132 @@ func (t *Tty) InScrollingRegion() bool {
 	return t.Cursor.Y >= t.ScrollRange.Low && t.Cursor.Y < t.ScrollRange.High
 }
 
-func (t *Tty) applyParOptions(attrs []byte, set bool) {
+// InMargins returns true if the cursor is between the left and right
+// margins.
+func (t *Tty) InMargins() bool {
+	x := intMin(t.Cursor.X, t.Size.X-1)
+	return x >= t.MarginRange.Low && x < t.MarginRange.High
+}
+
+func (t *Tty) applyParOptions(attrs []int, set bool) {
+	final := 'l'
+	if set {
+		final = 'h'
+	}
 	for _, attr := range attrs {
 		switch attr {
+		case 6:
+			t.OriginMode = set
+			t.cursorHome()
 		case 7:
 			t.AutoWrap = set
-		case 26:
+		case 12:
+			t.CursorStyle.Blink = set
+		case 25:
 			t.CursorVisible = set
+		case 69:
+			t.MarginMode = set
+			if !set {
+				t.MarginRange = Range{Low: 0, High: t.Size.X}
+			}
+		default:
+			t.unsupported("CSI ? %d %c", attr, final)
 		}
 	}
 }
 
-func (t *Tty) applyParAttrs(attrs []byte) {
-	mode := AttrModeNorm
-	for _, attr := range attrs {
-		mode = t.applyParAttr(mode, attr)
+func (t *Tty) applyParAttrs(attrs []int) {
+	for i := 0; i < len(attrs); i++ {
+		switch attrs[i] {
+		case 38, 48:
+			color, n, ok := t.parseExtColor(attrs[i:])
+			if ok {
+				if attrs[i] == 38 {
+					t.Attr = t.Attr.WithFg(color)
+				} else {
+					t.Attr = t.Attr.WithBg(color)
+				}
+			}
+			i += n - 1
+		default:
+			t.applyParAttr(attrs[i])
+		}
 	}
 }
 
-func (t *Tty) applyParAttr(mode AttrMode, attr byte) AttrMode {
-	switch mode {
-	case AttrMode38a:
-		if attr != 5 {
-			return AttrModeNorm
-		}
-		return AttrMode38b
-	case AttrMode38b:
-		if attr == 16 {
-			t.Attr &= ^Attribute(0xff)
-		} else {
-			t.Attr = (t.Attr & ^Attribute(0xff)) | (Attribute(attr) & 0xff)
+// parseExtColor parses the extended color at the start of attrs, which
+// begins with 38 or 48, and returns the color, the number of
+// parameters consumed and whether the color is valid. It consumes only
+// the 38/48 itself if the color is unsupported. Like xterm, it ignores
+// colors with components above 255 rather than wrapping them.
+func (t *Tty) parseExtColor(attrs []int) (Color, int, bool) {
+	if len(attrs) >= 3 && attrs[1] == 5 {
+		switch {
+		case attrs[2] > 255:
+			t.malformed("SGR color index out of range")
+			return DefaultColor, 3, false
+		case attrs[2] == 16:
+			// termrec maps the cube's black to palette black.
+			return IndexedColor(0), 3, true
 		}
-		return AttrModeNorm
-	case AttrMode48a:
-		if attr != 5 {
-			return AttrModeNorm
-		}
-		return AttrMode48b
-	case AttrMode48b:
-		if attr == 16 {
-			t.Attr &= ^Attribute(0xff00)
-		} else {
-			t.Attr = (t.Attr & ^Attribute(0xff00)) | (Attribute(attr) << 8)
+		return IndexedColor(uint8(attrs[2])), 3, true
+	}
+	if len(attrs) >= 5 && attrs[1] == 2 {
+		if attrs[2] > 255 || attrs[3] > 255 || attrs[4] > 255 {
+			t.malformed("SGR color component out of range")
+			return DefaultColor, 5, false
 		}
-		return AttrModeNorm
+		return RGBColor(uint8(attrs[2]), uint8(attrs[3]), uint8(attrs[4])), 5, true
 	}
+	// Other subcommands, none of which we support:
+	// * 3: CMY
+	// * 4: CMYK
+	if len(attrs) >= 2 {
+		t.unsupported("SGR %d;%d", attrs[0], attrs[1])
+		return DefaultColor, 2, false
+	}
+	t.unsupported("SGR %d", attrs[0])
+	return DefaultColor, 1, false
+}
 
+func (t *Tty) applyParAttr(attr int) {
 	switch attr {
 	case 0:
-		t.Attr = 0x1010
+		// DECSCA protection is not an SGR attribute.
+		t.Attr = DefaultStyle().With(t.Attr.Flags() & VT100AttrProtected)
 	case 1:
-		t.Attr |= VT100AttrBold
-		t.Attr &= ^VT100AttrDim
+		t.Attr = t.Attr.With(VT100AttrBold).Without(VT100AttrDim)
 	case 2:
-		t.Attr |= VT100AttrDim
-		t.Attr &= ^VT100AttrBold
+		t.Attr = t.Attr.With(VT100AttrDim).Without(VT100AttrBold)
 	case 3:
-		t.Attr |= VT100AttrItalic
+		t.Attr = t.Attr.With(VT100AttrItalic)
 	case 4:
-		t.Attr |= VT100AttrUnderline
+		t.Attr = t.Attr.With(VT100AttrUnderline)
 	case 5:
-		t.Attr |= VT100AttrBlink
+		t.Attr = t.Attr.With(VT100AttrBlink)
 	case 7:
-		t.Attr |= VT100AttrInverse
+		t.Attr = t.Attr.With(VT100AttrInverse)
 	case 21, 22:
-		t.Attr &= ^(VT100AttrBold | VT100AttrDim)
+		t.Attr = t.Attr.Without(VT100AttrBold | VT100AttrDim)
 	case 23:
-		t.Attr &= ^VT100AttrItalic
+		t.Attr = t.Attr.Without(VT100AttrItalic)
 	case 24:
-		t.Attr &= ^VT100AttrUnderline
+		t.Attr = t.Attr.Without(VT100AttrUnderline)
 	case 25:
-		t.Attr &= ^VT100AttrBlink
+		t.Attr = t.Attr.Without(VT100AttrBlink)
 	case 27:
-		t.Attr &= ^VT100AttrInverse
+		t.Attr = t.Attr.Without(VT100AttrInverse)
 	case 30, 31, 32, 33, 34, 35, 36, 37:
-		t.Attr = (t.Attr & ^Attribute(0xff)) | (Attribute(attr) - 30)
-	case 38:
-		// Other subcommands, none of which we support:
-		// * 2: RGB
-		// * 3: CMY
-		// * 4: CMYK
-		return AttrMode38a
+		t.Attr = t.Attr.WithFg(IndexedColor(uint8(attr - 30)))
 	case 39:
-		t.Attr = (t.Attr & ^Attribute(0xff)) | 0x10
+		t.Attr = t.Attr.WithFg(DefaultColor)
 	case 40, 41, 42, 43, 44, 45, 46, 47:
-		t.Attr = (t.Attr & ^Attribute(0xff00)) | ((Attribute(attr) - 40) << 8)
-	case 48:
-		return AttrMode48a
+		t.Attr = t.Attr.WithBg(IndexedColor(uint8(attr - 40)))
 	case 49:
-		t.Attr = (t.Attr & ^Attribute(0xff00)) | 0x1000
+		t.Attr = t.Attr.WithBg(DefaultColor)
+	default:
+		t.unsupported("SGR %d", attr)
 	}
-	return AttrModeNorm
 }
 
 func (t *Tty) isStateFull() bool {
@@ -585,42 +1067,7 @@ func (t *Tty) isStateFull() bool {
 func (t *Tty) clearParState() {
 	t.stateTok = t.stateTok[0:1]
 	t.stateTok[0] = 0
-}
-
-func (t *Tty) debug(msg string) {
-	if t.Debug {
-		fmt.Fprintln(os.Stderr, msg)
-	}
-}
-
-func (t *Tty) err(b byte) {
-	if t.Debug {
-		t.debugErr(b)
-	}
-	t.changeState(VTNorm)
-}
-
-func (t *Tty) escState() string {
-	buf := bytes.Buffer{}
-	for i, c := range t.stateTok {
-		if i > 0 {
-			buf.WriteByte(' ')
-		}
-		buf.WriteString(strconv.FormatUint(uint64(c), 10))
-	}
-	return buf.String()
-}
-
-func (t *Tty) debugErr(b byte) {
-	switch t.State {
-	case VTNorm:
-		fmt.Fprintf(os.Stderr, "Unknown code 0x%02x\n", b)
-	case VTEsc:
-		fmt.Fprintf(os.Stderr, "Unknown code ESC %c\n", rune(b))
-	case VTSquare, VTQues, VTPercent:
-		fmt.Fprintf(os.Stderr, "Unknown code ESC %s %s\n",
-			t.State.Code(), t.escState())
-	default:
-		fmt.Fprintln(os.Stderr, "Bad state for VT")
-	}
+	t.stateInter = 0
+	t.statePriv = 0
+	t.stateOverflow = false
 }
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -169,3 +170,387 @@ func TestBlobs(t *testing.T) {
 		}
 	}
 }
+
+func sbtxt(index int, text string) CheckFn {
+	return func(tty *Tty) string {
+		if index >= len(tty.Scrollback) {
+			return fmt.Sprintf("expected scrollback line %d==%#v, got %d lines",
+				index, text, len(tty.Scrollback))
+		}
+		actual := ""
+		for _, c := range tty.Scrollback[index].Cells[:len(text)] {
+			actual += string(c.Ch)
+		}
+		if actual != text {
+			return fmt.Sprintf("expected scrollback line %d==%#v, got %#v",
+				index, text, actual)
+		}
+		return ""
+	}
+}
+
+func sblen(n int) CheckFn {
+	return func(tty *Tty) string {
+		if len(tty.Scrollback) != n {
+			return fmt.Sprintf("expected %d scrollback lines, got %d",
+				n, len(tty.Scrollback))
+		}
+		return ""
+	}
+}
+
+type resizeCase struct {
+	size   Pt
+	checks []StateTest
+}
+
+func testResizes(t *testing.T, term *Tty, text string, cases []resizeCase) {
+	term.WriteString(text)
+	for _, test := range cases {
+		term.Resize(test.size)
+		for _, check := range test.checks {
+			if res := check.Test(term); res != "" {
+				t.Errorf("resize %#v to %s failed: %s", text, test.size, res)
+			}
+		}
+	}
+}
+
+func TestReflowResize(t *testing.T) {
+	term := NewSz(Pt{10, 3})
+	term.Reflow = true
+	term.ScrollbackLimit = 10
+	testResizes(t, term, "abcdefghijklmno", []resizeCase{
+		{Pt{5, 3}, checks(
+			cur(Pt{5, 2}),
+			txt(Pt{}, "abcde"), txt(Pt{Y: 1}, "fghij"), txt(Pt{Y: 2}, "klmno"),
+		)},
+		{Pt{20, 3}, checks(
+			cur(Pt{15, 0}),
+			txt(Pt{}, "abcdefghijklmno     "), txt(Pt{Y: 1}, "     "),
+		)},
+		{Pt{5, 2}, checks(
+			cur(Pt{5, 1}),
+			txt(Pt{}, "fghij"), txt(Pt{Y: 1}, "klmno"),
+			sblen(1), sbtxt(0, "abcde"),
+		)},
+		{Pt{20, 2}, checks(
+			cur(Pt{15, 0}),
+			txt(Pt{}, "abcdefghijklmno"),
+			sblen(0),
+		)},
+	})
+
+	term = NewSz(Pt{4, 3})
+	term.Reflow = true
+	testResizes(t, term, "abc\r\ndef", []resizeCase{
+		{Pt{2, 3}, checks(
+			cur(Pt{1, 2}),
+			txt(Pt{}, "c "), txt(Pt{Y: 1}, "de"), txt(Pt{Y: 2}, "f "),
+			sblen(0),
+		)},
+	})
+}
+
+func TestResizeSequence(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		size  Pt
+	}{
+		{"\033[8;3;7t", Pt{7, 3}},
+		{"\033[8;0;0t", Pt{20, 5}},
+		{"\033[8;;7t", Pt{7, 5}},
+		{"\033[8;3t", Pt{20, 3}},
+		{"\033[8;65535;2t", Pt{2, maxResizeDim}},
+	} {
+		term := NewSz(Pt{20, 5})
+		term.Resizable = true
+		term.WriteString(test.input)
+		if term.Size != test.size {
+			t.Errorf("%q: expected size %s, got %s", test.input, test.size,
+				term.Size)
+		}
+	}
+}
+
+func TestScrollback(t *testing.T) {
+	term := NewSz(Pt{10, 2})
+	term.ScrollbackLimit = 2
+	term.WriteString("1\r\n2\r\n3\r\n4")
+	for _, check := range checks(sblen(2), sbtxt(0, "1 "), sbtxt(1, "2 ")) {
+		if res := check.Test(term); res != "" {
+			t.Errorf("scrollback: %s", res)
+		}
+	}
+	term.WriteString("\r\n5")
+	for _, check := range checks(sblen(2), sbtxt(0, "2 "), sbtxt(1, "3 ")) {
+		if res := check.Test(term); res != "" {
+			t.Errorf("scrollback: %s", res)
+		}
+	}
+	term.WriteString("\033[3J")
+	if res := sblen(0).Test(term); res != "" {
+		t.Errorf("scrollback after ED 3: %s", res)
+	}
+}
+
+func TestCells(t *testing.T) {
+	// Scrolling rotates the ring of rows; Cells still starts at the top.
+	term := NewSz(Pt{3, 2})
+	term.WriteString("ab\r\ncd\r\nef")
+	cells := term.Cells()
+	text := ""
+	for _, c := range cells {
+		text += string(c.Ch)
+	}
+	if text != "cd ef " {
+		t.Errorf("expected %q, got %q", "cd ef ", text)
+	}
+	cells[0].Ch = 'x'
+	if term.Get(Pt{}).Ch != 'c' {
+		t.Errorf("Cells didn't return a copy")
+	}
+}
+
+func TestTitle(t *testing.T) {
+	term := New()
+	for _, test := range []struct {
+		text, title string
+	}{
+		{"\033]0;first\007", "first"},
+		{"\033]2;second\033\\", "second"},
+		{"\033]1;icon only\007", "second"},
+		{"\033]2title\007", "second"},
+		{"\033c", ""},
+	} {
+		term.WriteString(test.text)
+		if term.Title != test.title {
+			t.Errorf("after %#v: expected title %#v, got %#v",
+				test.text, test.title, term.Title)
+		}
+	}
+}
+
+func TestCursorStyle(t *testing.T) {
+	term := New()
+	for _, test := range []struct {
+		text    string
+		style   CursorStyle
+		visible bool
+	}{
+		{"", CursorStyle{CursorBlock, true}, true},
+		{"\033[4 q", CursorStyle{CursorUnderline, false}, true},
+		{"\033[?12h", CursorStyle{CursorUnderline, true}, true},
+		{"\033[6 q\033[?25l", CursorStyle{CursorBar, false}, false},
+		{"\033[9 q\033[?25h", CursorStyle{CursorBar, false}, true},
+		{"\033[?12h\033[2 q", CursorStyle{CursorBlock, false}, true},
+		{"\033[0 q", CursorStyle{CursorBlock, true}, true},
+		{"\033[3 q\033c", CursorStyle{CursorBlock, true}, true},
+	} {
+		term.WriteString(test.text)
+		if term.CursorStyle != test.style || term.CursorVisible != test.visible {
+			t.Errorf("after %#v: expected cursor %+v visible=%v, got %+v visible=%v",
+				test.text, test.style, test.visible,
+				term.CursorStyle, term.CursorVisible)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	term := New()
+	counter := NewDiagnosticCounter()
+	term.Observer = counter
+	term.WriteString("ab\033[?1049h\033[?1049lc\033[4h\033Z\033(A" +
+		"\033[1;2;3;4;5;6;7;8;9;10;11;12m\033[9m\xc3(d\xff")
+
+	for _, want := range []struct {
+		name  string
+		count int
+	}{
+		{"CSI ? 1049 h", 1},
+		{"CSI ? 1049 l", 1},
+		{"CSI 4 h", 1},
+		{"ESC Z", 1},
+		{"ESC ( A", 1},
+		{"CSI too many parameters", 1},
+		{"SGR 9", 1},
+		{"UTF-8 truncated", 1},
+		{"UTF-8 bad byte", 1},
+	} {
+		if count := counter.Count(want.name); count != want.count {
+			t.Errorf("expected %d %#v diagnostics, got %d",
+				want.count, want.name, count)
+		}
+	}
+	if counts := counter.Counts(); len(counts) != 9 {
+		t.Errorf("expected 9 kinds of diagnostic, got %v", counts)
+	}
+
+	for _, count := range counter.Counts() {
+		d := count.First
+		if d.Name == "CSI 4 h" && (d.Offset != 19 ||
+			string(d.Seq) != "\033[4h" || d.Frame != -1) {
+			t.Errorf("unexpected diagnostic: %+v", d)
+		}
+	}
+	if text := term.TextAtN(Pt{}, 5); text != "abc(d" {
+		t.Errorf("expected text \"abc(d\", got %#v", text)
+	}
+}
+
+func TestDiagnosticResetAndResize(t *testing.T) {
+	term := New()
+	counter := NewDiagnosticCounter()
+	term.Observer = counter
+	term.WriteString("\033]0;half a title")
+	term.Reset()
+	term.Resize(Pt{100, 30})
+	term.Resize(Pt{90, 20})
+
+	if counts := counter.Counts(); len(counts) != 1 || counts[0].Count != 2 {
+		t.Fatalf("expected only 2 resize diagnostics, got %v", counts)
+	}
+	if d := counter.Counts()[0].First; d.Name != "resize" || d.Detail != "100x30" {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestStyle(t *testing.T) {
+	term := New()
+	term.WriteString("\033[1;31;44ma\033[38;5;200;48;2;1;2;3;4mb" +
+		"\033[22;39;49;7mc\033[38;5;16m\033[0md\033[38;3;1me" +
+		"\033[32;38;5;256;48;2;1;256;3mf")
+
+	for _, want := range []struct {
+		x     int
+		style Style
+		desc  string
+	}{
+		{0, DefaultStyle().With(VT100AttrBold).
+			WithFg(IndexedColor(1)).WithBg(IndexedColor(4)),
+			"fg=1 bg=4 bold"},
+		{1, DefaultStyle().With(VT100AttrBold | VT100AttrUnderline).
+			WithFg(IndexedColor(200)).WithBg(RGBColor(1, 2, 3)),
+			"fg=200 bg=#010203 bold underline"},
+		{2, DefaultStyle().With(VT100AttrUnderline | VT100AttrInverse),
+			"underline inverse"},
+		{3, DefaultStyle(), "default"},
+		{4, DefaultStyle().With(VT100AttrBold), "bold"},
+		// Out of range colors are ignored, not wrapped.
+		{5, DefaultStyle().With(VT100AttrBold).WithFg(IndexedColor(2)),
+			"fg=2 bold"},
+	} {
+		style := term.Get(Pt{X: want.x}).Attr
+		if style != want.style || style.String() != want.desc {
+			t.Errorf("cell %d: expected %s, got %s", want.x, want.desc, style)
+		}
+	}
+
+	s := term.Get(Pt{X: 1}).Attr
+	if !s.Bold() || s.Dim() || !s.Underline() || s.Inverse() {
+		t.Errorf("unexpected flags for %s", s)
+	}
+	if n, ok := s.Fg().Index(); !ok || n != 200 {
+		t.Errorf("expected fg index 200, got %d, %v", n, ok)
+	}
+	if r, g, b, ok := s.Bg().RGB(); !ok || r != 1 || g != 2 || b != 3 {
+		t.Errorf("expected bg #010203, got %s", s.Bg())
+	}
+	if _, ok := s.Bg().Index(); ok {
+		t.Errorf("RGB color %s reported a palette index", s.Bg())
+	}
+	if !DefaultColor.IsDefault() || !(AttrChar{}).Attr.IsDefault() {
+		t.Errorf("zero values are not the default style")
+	}
+}
+
+func TestDebugAttr(t *testing.T) {
+	for _, want := range []struct {
+		style Style
+		word  string
+	}{
+		{DefaultStyle(), "1010"},
+		{DefaultStyle().WithFg(IndexedColor(1)).WithBg(IndexedColor(4)).
+			With(VT100AttrBold), "10401"},
+		{DefaultStyle().With(VT100AttrProtected), "401010"},
+		{DefaultStyle().WithFg(IndexedColor(16)), "1001000/16"},
+		{DefaultStyle().WithBg(RGBColor(1, 2, 3)), "2000010/#010203"},
+	} {
+		if word := debugAttr(want.style); word != want.word {
+			t.Errorf("%s: expected %s, got %s", want.style, want.word, word)
+		}
+	}
+}
+
+// TestWritePrintable checks that the fast path for printable runs in
+// Write has the same effect as consuming each byte in turn.
+func TestWritePrintable(t *testing.T) {
+	for _, input := range []string{
+		"Hello, world",
+		strings.Repeat("wrapping text ", 20),
+		"\033[?7l" + strings.Repeat("no autowrap ", 20),
+		"\033[?69h\033[5;15s\033[1;5H" + strings.Repeat("margins ", 10),
+		"\033[?69h\033[5;15s\033[1;18H" + strings.Repeat("outside ", 3),
+		"\033[?69h\033[?7l\033[5;15s\033[1;5H" + strings.Repeat("x", 30),
+		"\033(0lqqk\033(Bok\016abc\017def",
+		"\033[1;31mred\033[0m\r\nplain\033[Hover\b\bX",
+		"caf\xc3\xa9 \xe2\x98\xba done",
+	} {
+		for _, utf8 := range []bool{true, false} {
+			fast, slow := NewSz(Pt{20, 5}), NewSz(Pt{20, 5})
+			fast.UTF8, slow.UTF8 = utf8, utf8
+			fast.WriteString(input)
+			for _, b := range []byte(input) {
+				slow.consumeByte(b)
+			}
+			if fast.DebugDump() != slow.DebugDump() ||
+				fast.lastChar != slow.lastChar || fast.offset != slow.offset {
+				t.Errorf("%#v (UTF8=%v): expected\n%s\ngot\n%s", input, utf8,
+					slow.DebugDump(), fast.DebugDump())
+			}
+		}
+	}
+}
+
+// wideText returns the text of row y, with _ for the right halves of
+// double-width characters.
+func wideText(t *Tty, y int) string {
+	var text []rune
+	for _, c := range t.row(y) {
+		if c.Ch == WideContinuation {
+			c.Ch = '_'
+		}
+		text = append(text, c.Ch)
+	}
+	return string(text)
+}
+
+func TestWide(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		rows  []string
+	}{
+		{"a中b", []string{"a中_b  ", "      "}},
+		{"中文\033[1;2Hx", []string{" x文_  ", "      "}},
+		{"中文\033[1;3Hx", []string{"中_x   ", "      "}},
+		{"中文\033[1;2H字", []string{" 字_   ", "      "}},
+		{"abcde中", []string{"abcde ", "中_    "}},
+		{"\033[?7labcde中", []string{"abcd中_", "      "}},
+		{"中文字\033[1;4H\033[K", []string{"中_    ", "      "}},
+		{"中文字\033[1;4H\033[1K", []string{"    字_", "      "}},
+		{"中文字\033[1;2H\033[P", []string{" 文_字_ ", "      "}},
+		{"中文字\033[1;2H\033[@", []string{"   文_ ", "      "}},
+	} {
+		term := NewSz(Pt{6, 2})
+		term.WriteString(test.input)
+		for y, want := range test.rows {
+			if got := wideText(term, y); got != want {
+				t.Errorf("%q: row %d: expected %q, got %q", test.input, y,
+					want, got)
+			}
+		}
+		if dump := term.DebugDump(); strings.Contains(dump, "[FFFFFFFF]") {
+			t.Errorf("%q: dump shows continuation cells:\n%s", test.input, dump)
+		}
+	}
+}
@@ -12,6 +12,8 @@ func (t *Tty) InBounds(p Pt) bool {
 // TextAtN returns a string of length runes at the given position. If
 // the position is outside the terminal, returns the empty string. If
 // the requested length is too large, returns the maximum available.
+// The right halves of double-width characters are left out, so the
+// string may be fewer runes than length.
 //
 // The returned string may contain invalid UTF-8, including the zero
 // byte.
@@ -22,14 +24,15 @@ func (t *Tty) TextAtN(at Pt, length int) string {
 
 	out := bytes.Buffer{}
 	offset := t.posOffset(at)
-	maxOffset := t.Size.Area()
-	end := offset + length
-	if end > maxOffset {
-		end = maxOffset
-	}
-	for _, c := range t.Buf[offset:end] {
-		out.WriteRune(c.Ch)
-	}
+	length = intMin(length, t.Size.Area()-offset)
+	t.spans(offset, length, func(cells []AttrChar) {
+		for _, c := range cells {
+			if c.Ch == WideContinuation {
+				continue
+			}
+			out.WriteRune(c.Ch)
+		}
+	})
 	return out.String()
 }
 
@@ -37,64 +40,166 @@ func (t *Tty) WriteString(content string) {
 	t.Write([]byte(content))
 }
 
+// Write interprets content as output to the terminal. Any input is
+// safe: malformed or hostile sequences are ignored or clamped to the
+// screen, never a panic.
 func (t *Tty) Write(content []byte) {
-	for _, b := range content {
-		t.consumeByte(b)
+	for i := 0; i < len(content); {
+		if t.State == VTNorm && t.utfCount == 0 {
+			if n := t.writePrintable(content[i:]); n > 0 {
+				i += n
+				continue
+			}
+		}
+		t.consumeByte(content[i])
+		i++
 	}
 }
 
+// ClearRegion erases length cells from offset start of the screen,
+// counting across and then down. The part of the region outside the
+// screen is ignored.
 func (t *Tty) ClearRegion(start, length int) {
+	end := clamp(start+length, 0, t.Size.Area())
+	start = clamp(start, 0, end)
+	length = end - start
 	zero := t.DefaultAttrChar()
-	region := t.Buf[start : length+start]
-	for i := range region {
-		region[i] = zero
-	}
+	t.editSpans(start, length, func(region []AttrChar) {
+		for i := range region {
+			region[i] = zero
+		}
+	})
+	t.repairWideAt(start, start+length)
 }
 
+// Resize changes the terminal size. Content outside the new size is
+// lost, unless Reflow is set, in which case soft-wrapped lines are
+// rewrapped to the new width and lines pushed off the top go to the
+// scrollback. The terminal is never made smaller than 1x1.
 func (t *Tty) Resize(newsize Pt) {
+	newsize = Pt{X: intMax(newsize.X, 1), Y: intMax(newsize.Y, 1)}
 	if newsize == t.Size {
 		return
 	}
 	oldsize := t.Size
-	t.debug(fmt.Sprintf("Resize from %s -> %s",
-		oldsize.String(), newsize.String()))
+	if t.reporting() {
+		t.reportDetail(DiagnosticInfo, "resize",
+			fmt.Sprintf("%dx%d", newsize.X, newsize.Y), nil, t.offset)
+	}
+	// The margins are reset, so a pending wrap at the right margin
+	// no longer applies.
+	t.marginWrap = false
+	if t.Reflow {
+		t.reflowResize(newsize)
+		return
+	}
 
-	oldbuf := t.Buf
+	oldrows := t.screenRows()
+	oldwrapped := t.wrapped
 
 	t.Size = newsize
-	t.Buf = t.allocBuf(t.Size)
+	t.allocScreen()
 	t.ClearRegion(0, newsize.Area())
 
-	var oldOffset, newOffset int
+	t.resizeTabStops(newsize.X)
+	t.wrapped = make([]bool, newsize.Y)
+	if newsize.X == oldsize.X {
+		copy(t.wrapped, oldwrapped)
+	}
+
 	copysize := PointMin(oldsize, newsize)
 	for y := 0; y < copysize.Y; y++ {
-		copy(t.Buf[newOffset:newOffset+copysize.X],
-			oldbuf[oldOffset:oldOffset+copysize.X])
-		oldOffset += oldsize.X
-		newOffset += newsize.X
+		row := t.editRow(y)
+		copy(row[:copysize.X], oldrows[y])
+		repairWideRow(row)
 	}
 	t.ScrollRange = Range{Low: 0, High: newsize.Y}
+	t.MarginRange = Range{Low: 0, High: newsize.X}
 	t.Cursor.X = clamp(t.Cursor.X, 0, newsize.X)
 	t.Cursor.Y = clamp(t.Cursor.Y, 0, newsize.Y-1)
+	t.savedCursor = Pt{
+		X: clamp(t.savedCursor.X, 0, newsize.X),
+		Y: clamp(t.savedCursor.Y, 0, newsize.Y-1),
+	}
 }
 
 func (t *Tty) ClearScreen() {
 	t.Cursor = Pt{}
 	t.ClearRegion(0, t.Size.Area())
+	t.clearWrapped(0, t.Size.Y)
 }
 
+// Reset does a full reset (RIS). It clears the screen and title, and
+// restores the default modes, cursor style, character sets, and tab
+// stops (every 8 columns). If ResetSize is set, it also restores the
+// size the terminal was created with. Scrollback is kept.
+//
+// The parser is left in VTNorm with no parameters or OSC string
+// pending, and any partial UTF-8 character is dropped.
 func (t *Tty) Reset() {
+	// Drop any sequence in progress without finishing it, so that a
+	// half-written OSC isn't reported as malformed.
+	t.oscBuf = t.oscBuf[:0]
+	t.clearParState()
+	t.State = VTNorm
+	t.utfChar = 0
+	t.utfCount = 0
+
+	if t.ResetSize {
+		t.Resize(t.initialSize)
+	}
+	t.SoftReset()
 	t.Cursor = Pt{}
-	t.Attr = 0x1010
+	t.CursorStyle = DefaultCursorStyle()
+	t.Title = ""
+	t.lastChar = 0
+	t.resetTabStops(0)
+	t.ClearRegion(0, t.bufSize())
+	t.clearWrapped(0, t.Size.Y)
+}
+
+// SoftReset does a soft reset (DECSTR), which restores the default
+// modes, attributes, margins and character sets, but leaves the
+// screen, cursor position and tab stops alone.
+func (t *Tty) SoftReset() {
+	t.Attr = DefaultStyle()
 	t.CursorVisible = true
 	t.AutoWrap = true
 	t.Kpad = false
 	t.ScrollRange = Range{0, t.Size.Y}
+	t.MarginRange = Range{0, t.Size.X}
+	t.MarginMode = false
+	t.OriginMode = false
+	t.marginWrap = false
 	t.savedCursor = Pt{}
 	t.csetShift = 0
 	t.csetSelect = 1 << 1
-	t.utfCount = 0
-	t.ClearRegion(0, t.bufSize())
-	t.changeState(VTNorm)
-	t.clearParState()
+}
+
+// Clone returns a deep copy of the terminal, including its parser
+// state, that can be written to independently of t. The copy has no
+// callbacks or Observer.
+func (t *Tty) Clone() *Tty {
+	c := *t
+	c.allocScreen()
+	for y := range c.rows {
+		copy(c.rows[y], t.row(y))
+		c.snapRows[y] = t.snapRows[t.ringIndex(y)]
+	}
+	c.Scrollback = append([]Line(nil), t.Scrollback...)
+	c.stateTok = append(make([]int, 0, cap(t.stateTok)), t.stateTok...)
+	c.seqBuf = append([]byte(nil), t.seqBuf...)
+	c.oscBuf = append([]byte(nil), t.oscBuf...)
+	c.wrapped = append([]bool(nil), t.wrapped...)
+	c.tabStops = append([]bool(nil), t.tabStops...)
+
+	c.Observer = nil
+	c.CursorMoved = nil
+	c.CharWritten = nil
+	c.Cleared = nil
+	c.Scrolled = nil
+	c.Resized = nil
+	c.Flushed = nil
+	c.Bell = nil
+	return &c
 }
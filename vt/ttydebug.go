@@ -6,21 +6,23 @@ import (
 )
 
 // DebugDump returns a string with a debug dump of the tty content,
-// matching the dumps produced in termrec's tests.
+// matching the dumps produced in termrec's tests. The right half of a
+// double-width character is left out, so such rows are short.
 func (t *Tty) DebugDump() string {
 	out := &bytes.Buffer{}
 	fmt.Fprintf(out, ".-===[ %dx%d ]\n", t.Size.X, t.Size.Y)
-	attr := Attribute(0x1010)
+	attr := DefaultStyle()
 
 	for y := 0; y < t.Size.Y; y++ {
 		fmt.Fprint(out, "| ")
 
-		baseOffset := y * t.Size.X
-		for x := 0; x < t.Size.X; x++ {
-			c := t.Buf[baseOffset+x]
+		for _, c := range t.row(y) {
+			if c.Ch == WideContinuation {
+				continue
+			}
 			if c.Attr != attr {
 				attr = c.Attr
-				fmt.Fprintf(out, "{%X}", attr)
+				fmt.Fprintf(out, "{%s}", debugAttr(attr))
 			}
 			if c.Ch >= ' ' && c.Ch < 127 {
 				fmt.Fprintf(out, "%c", c.Ch)
@@ -33,3 +35,28 @@ func (t *Tty) DebugDump() string {
 	fmt.Fprintf(out, "`-===[ cursor at %d,%d]\n", t.Cursor.X, t.Cursor.Y)
 	return out.String()
 }
+
+// debugAttr formats s as termrec's attribute word: the foreground
+// palette index in the low byte and the background in the next, with
+// 0x10 for the default color, then the flags from bit 16. A color the
+// word can't hold, palette color 16 or an RGB color, sets bit 24 for the
+// foreground or 25 for the background and follows the word, as in
+// 1001000/#ff0000.
+func debugAttr(s Style) string {
+	word := uint32(s.Flags()) << 16
+	extra := ""
+	for i, c := range []Color{s.Fg(), s.Bg()} {
+		shift := uint(8 * i)
+		n, indexed := c.Index()
+		switch {
+		case c.IsDefault():
+			word |= 0x10 << shift
+		case indexed && n != 0x10:
+			word |= uint32(n) << shift
+		default:
+			word |= 1 << (24 + uint(i))
+			extra += "/" + c.String()
+		}
+	}
+	return fmt.Sprintf("%X%s", word, extra)
+}
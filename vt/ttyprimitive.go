@@ -10,26 +10,62 @@ func clamp(x, min, max int) int {
 	return x
 }
 
-func (t *Tty) clampCursor(c Pt) Pt {
-	return Pt{
-		X: clamp(c.X, 0, t.Size.X),
-		Y: clamp(c.Y, 0, t.Size.Y),
+// origin returns the position of the home cursor: the top left
+// corner of the screen, or of the scrolling region and margins in
+// origin mode.
+func (t *Tty) origin() Pt {
+	if t.OriginMode {
+		return Pt{X: t.MarginRange.Low, Y: t.ScrollRange.Low}
 	}
+	return Pt{}
 }
 
-// Clamps cursor strictly within bounds
-func (t *Tty) clampCursorStrict(c Pt) Pt {
-	return Pt{
-		X: clamp(c.X, 0, t.Size.X-1),
-		Y: clamp(c.Y, 0, t.Size.Y-1),
+func (t *Tty) cursorHome() {
+	t.Cursor = t.origin()
+}
+
+// setCursorX moves the cursor to column x, counted from the left
+// margin in origin mode.
+func (t *Tty) setCursorX(x int) {
+	low, high := 0, t.Size.X
+	if t.OriginMode {
+		low, high = t.MarginRange.Low, t.MarginRange.High
+	}
+	t.Cursor.X = clamp(low+x, low, high-1)
+}
+
+// setCursorY moves the cursor to row y, counted from the top of the
+// scrolling region in origin mode.
+func (t *Tty) setCursorY(y int) {
+	low, high := 0, t.Size.Y
+	if t.OriginMode {
+		low, high = t.ScrollRange.Low, t.ScrollRange.High
 	}
+	t.Cursor.Y = clamp(low+y, low, high-1)
 }
 
+// cursorMove moves the cursor by delta, stopping at the edges of the
+// screen. Vertical moves that start inside the scrolling region stop
+// at its margins.
 func (t *Tty) cursorMove(delta Pt) {
-	t.Cursor = t.clampCursor(Pt{
-		X: t.Cursor.X + delta.X,
-		Y: t.Cursor.Y + delta.Y,
-	})
+	minY, maxY := 0, t.Size.Y-1
+	if delta.Y < 0 && t.Cursor.Y >= t.ScrollRange.Low {
+		minY = t.ScrollRange.Low
+	}
+	if delta.Y > 0 && t.Cursor.Y < t.ScrollRange.High {
+		maxY = t.ScrollRange.High - 1
+	}
+	t.Cursor = Pt{
+		X: clamp(t.Cursor.X+delta.X, 0, t.Size.X-1),
+		Y: clamp(t.Cursor.Y+delta.Y, minY, maxY),
+	}
+}
+
+func (t *Tty) bell() {
+	t.Bells++
+	if t.Bell != nil {
+		t.Bell(t)
+	}
 }
 
 func (t *Tty) backspace() {
@@ -41,17 +77,75 @@ func (t *Tty) backspace() {
 	}
 }
 
-func (t *Tty) IsTabStop(x int) bool { return (x & 7) == 0 }
+func (t *Tty) IsTabStop(x int) bool {
+	return x >= 0 && x < len(t.tabStops) && t.tabStops[x]
+}
+
+// resetTabStops sets tab stops every 8 columns from column x on.
+func (t *Tty) resetTabStops(x int) {
+	for ; x < len(t.tabStops); x++ {
+		t.tabStops[x] = (x & 7) == 0
+	}
+}
+
+// resizeTabStops keeps the tab stops in the first width columns, and
+// adds default tab stops if the terminal got wider.
+func (t *Tty) resizeTabStops(width int) {
+	old := t.tabStops
+	t.tabStops = make([]bool, width)
+	copy(t.tabStops, old)
+	t.resetTabStops(len(old))
+}
 
 func (t *Tty) posOffset(p Pt) int { return t.Size.Offset(p) }
 func (t *Tty) maxOffset() int     { return t.Size.Area() }
 
-func (t *Tty) Get(p Pt) AttrChar      { return t.Buf[t.posOffset(p)] }
-func (t *Tty) Set(p Pt, ach AttrChar) { t.Buf[t.posOffset(p)] = ach }
+func (t *Tty) Get(p Pt) AttrChar      { return t.row(p.Y)[p.X] }
+func (t *Tty) Set(p Pt, ach AttrChar) { t.editRow(p.Y)[p.X] = ach }
 
+// Cells returns a copy of the screen, row by row from the top, so that
+// the cell at p is at Size.Offset(p). It replaces the Buf field the
+// screen used to be kept in.
+func (t *Tty) Cells() []AttrChar {
+	cells := make([]AttrChar, 0, t.Size.Area())
+	for y := 0; y < t.Size.Y; y++ {
+		cells = append(cells, t.row(y)...)
+	}
+	return cells
+}
+
+// spans calls fn with the part of each row covered by the length cells
+// starting at offset start, counting offsets across rows.
+func (t *Tty) spans(start, length int, fn func([]AttrChar)) {
+	for length > 0 {
+		y, x := start/t.Size.X, start%t.Size.X
+		n := intMin(length, t.Size.X-x)
+		fn(t.row(y)[x : x+n])
+		start += n
+		length -= n
+	}
+}
+
+// editSpans is spans for writing: it marks the rows it covers changed
+// since the last snapshot.
+func (t *Tty) editSpans(start, length int, fn func([]AttrChar)) {
+	if length <= 0 {
+		return
+	}
+	for y := start / t.Size.X; y <= (start+length-1)/t.Size.X; y++ {
+		t.editRow(y)
+	}
+	t.spans(start, length, fn)
+}
+
+// tab moves to the next tab stop, or the last column if there is
+// none, blanking the cells it passes over.
 func (t *Tty) tab() {
 	z := t.DefaultAttrChar()
-	for t.Cursor.X < t.Size.X {
+	if t.Cursor.X > t.Size.X-1 {
+		t.Cursor.X = t.Size.X - 1
+	}
+	for t.Cursor.X < t.Size.X-1 {
 		t.Set(t.Cursor, z)
 		t.Cursor.X++
 		if t.IsTabStop(t.Cursor.X) {
@@ -60,12 +154,27 @@ func (t *Tty) tab() {
 	}
 }
 
+func (t *Tty) backTab() {
+	if t.Cursor.X > t.Size.X-1 {
+		t.Cursor.X = t.Size.X - 1
+	}
+	for t.Cursor.X > 0 {
+		t.Cursor.X--
+		if t.IsTabStop(t.Cursor.X) {
+			break
+		}
+	}
+}
+
 func (t *Tty) linefeed() {
 	t.carriageReturn()
 	t.verticaltab()
 }
 
 func (t *Tty) upline() {
+	if t.Cursor.Y == t.ScrollRange.Low && !t.InMargins() {
+		return
+	}
 	t.Cursor.Y--
 	if t.Cursor.Y == t.ScrollRange.Low-1 {
 		t.Cursor.Y = t.ScrollRange.Low
@@ -78,9 +187,12 @@ func (t *Tty) upline() {
 }
 
 func (t *Tty) verticaltab() {
+	if t.Cursor.Y == t.ScrollRange.High-1 && !t.InMargins() {
+		return
+	}
 	t.Cursor.Y++
 	if t.Cursor.Y == t.ScrollRange.High {
-		t.Scroll(t.ScrollRange.High - t.Cursor.Y + 1)
+		t.scrollUp(t.ScrollRange.High - t.Cursor.Y + 1)
 		t.Cursor.Y = t.ScrollRange.High - 1
 	} else if t.Cursor.Y >= t.Size.Y {
 		t.Cursor.Y = t.Size.Y - 1
@@ -91,13 +203,31 @@ func (t *Tty) formfeed() {
 	t.ClearScreen()
 }
 
+// carriageReturn moves to the left margin, or to the first column if
+// the cursor is already left of the margin.
 func (t *Tty) carriageReturn() {
-	t.Cursor.X = 0
+	if t.Cursor.X >= t.MarginRange.Low {
+		t.Cursor.X = t.MarginRange.Low
+	} else {
+		t.Cursor.X = 0
+	}
 }
 
 func (t *Tty) clampCursorX() {
+	if t.marginWrap && t.Cursor == t.marginWrapAt {
+		t.marginWrap = false
+		if t.AutoWrap {
+			t.Cursor.X = t.MarginRange.Low
+			t.verticaltab()
+		} else {
+			t.Cursor.X = t.MarginRange.High - 1
+		}
+		return
+	}
+	t.marginWrap = false
 	if t.Cursor.X >= t.Size.X {
 		if t.AutoWrap {
+			t.wrapped[t.Cursor.Y] = true
 			t.Cursor.X = 0
 			t.verticaltab()
 		} else {
@@ -105,3 +235,107 @@ func (t *Tty) clampCursorX() {
 		}
 	}
 }
+
+// eraseRegion clears length cells starting at offset start. If
+// selective is set, cells protected by DECSCA are left alone.
+func (t *Tty) eraseRegion(start, length int, selective bool) {
+	if !selective {
+		t.ClearRegion(start, length)
+		return
+	}
+	zero := t.DefaultAttrChar()
+	t.editSpans(start, length, func(region []AttrChar) {
+		for i := range region {
+			if !region[i].Attr.Protected() {
+				region[i] = zero
+			}
+		}
+	})
+	t.repairWideAt(start, start+length)
+}
+
+// cursorCellWidth returns 1 if the cursor is on a cell, or 0 if it is
+// past the right edge waiting to wrap.
+func (t *Tty) cursorCellWidth() int {
+	if t.Cursor.X < t.Size.X {
+		return 1
+	}
+	return 0
+}
+
+// eraseDisplay implements ED (and DECSED if selective).
+func (t *Tty) eraseDisplay(mode int, selective bool) {
+	switch mode {
+	case 0: // from cursor
+		offset := t.posOffset(t.Cursor)
+		t.eraseRegion(offset, t.maxOffset()-offset, selective)
+		t.clearWrapped(t.Cursor.Y, t.Size.Y)
+	case 1: // to cursor, inclusive
+		t.eraseRegion(0, t.posOffset(t.Cursor)+t.cursorCellWidth(), selective)
+		t.clearWrapped(0, t.Cursor.Y)
+	case 2: // full screen
+		t.eraseRegion(0, t.maxOffset(), selective)
+		t.clearWrapped(0, t.Size.Y)
+	case 3: // scrollback
+		t.Scrollback = nil
+	}
+}
+
+// eraseLine implements EL (and DECSEL if selective).
+func (t *Tty) eraseLine(mode int, selective bool) {
+	switch mode {
+	case 0: // from cursor
+		t.eraseRegion(t.posOffset(t.Cursor), t.Size.X-t.Cursor.X, selective)
+		t.wrapped[t.Cursor.Y] = false
+	case 1: // to cursor, inclusive
+		t.eraseRegion(t.posOffset(Pt{Y: t.Cursor.Y}),
+			t.Cursor.X+t.cursorCellWidth(), selective)
+	case 2:
+		t.eraseRegion(t.posOffset(Pt{Y: t.Cursor.Y}), t.Size.X, selective)
+		t.wrapped[t.Cursor.Y] = false
+	}
+}
+
+// marginLine returns the part of the cursor line from the cursor to
+// the right margin, for writing, or nil if the cursor is outside the
+// margins.
+func (t *Tty) marginLine() []AttrChar {
+	x := intMin(t.Cursor.X, t.Size.X-1)
+	if x < t.MarginRange.Low || x >= t.MarginRange.High {
+		return nil
+	}
+	return t.editRow(t.Cursor.Y)[x:t.MarginRange.High]
+}
+
+// insertChars inserts n blanks at the cursor, shifting the rest of the
+// line right. Characters pushed past the right margin are lost.
+func (t *Tty) insertChars(n int) {
+	line := t.marginLine()
+	n = intMin(n, len(line))
+	copy(line[n:], line)
+	zero := t.DefaultAttrChar()
+	for i := range line[:n] {
+		line[i] = zero
+	}
+	repairWideRow(t.editRow(t.Cursor.Y))
+}
+
+// deleteChars deletes n characters at the cursor, shifting the rest of
+// the line left and filling in blanks at the right margin.
+func (t *Tty) deleteChars(n int) {
+	line := t.marginLine()
+	n = intMin(n, len(line))
+	copy(line, line[n:])
+	zero := t.DefaultAttrChar()
+	tail := line[len(line)-n:]
+	for i := range tail {
+		tail[i] = zero
+	}
+	repairWideRow(t.editRow(t.Cursor.Y))
+}
+
+func (t *Tty) clearWrapped(low, high int) {
+	for y := low; y < high; y++ {
+		t.wrapped[y] = false
+	}
+}
@@ -0,0 +1,103 @@
+package vt
+
+import "github.com/greensnark/go-footv/unicode"
+
+// WideContinuation is the Ch of the cell covered by the right half of a
+// double-width character, which is in the cell to its left. The Tty
+// never leaves either half without the other: overwriting or erasing
+// one half blanks the other.
+const WideContinuation rune = -1
+
+// IsWide reports whether the character in c takes two cells.
+func (c AttrChar) IsWide() bool {
+	return c.Ch > 0x7f && unicode.IsWide(c.Ch)
+}
+
+// putWide writes the double-width character c at the cursor and the
+// cell after it, wrapping first if there is no room for both before
+// the right margin. Without autowrap, it overwrites the last two cells
+// of the line instead.
+func (t *Tty) putWide(c rune) {
+	t.clampCursorX()
+	high := t.Size.X
+	if t.Cursor.X < t.MarginRange.High {
+		high = t.MarginRange.High
+	}
+	if high < 2 {
+		return
+	}
+	if t.Cursor.X+2 > high {
+		switch {
+		case !t.AutoWrap:
+			t.Cursor.X = high - 2
+		case high < t.Size.X:
+			t.Cursor.X = high
+			t.marginWrap = true
+			t.marginWrapAt = t.Cursor
+			t.clampCursorX()
+		default:
+			t.Cursor.X = t.Size.X
+			t.clampCursorX()
+		}
+		if t.Cursor.X+2 > high {
+			// The line or margins are too narrow for the character.
+			return
+		}
+	}
+	row := t.editRow(t.Cursor.Y)
+	x := t.Cursor.X
+	row[x] = AttrChar{Attr: t.Attr, Ch: c}
+	row[x+1] = AttrChar{Attr: t.Attr, Ch: WideContinuation}
+	repairWide(row, x, x+2)
+	t.Cursor.X += 2
+	if t.Cursor.X == t.MarginRange.High && t.Cursor.X < t.Size.X {
+		t.marginWrap = true
+		t.marginWrapAt = t.Cursor
+	}
+	t.lastChar = c
+}
+
+// repairWide blanks the halves of double-width characters left at the
+// edges of row[low:high] after the cells in it were overwritten.
+func repairWide(row []AttrChar, low, high int) {
+	if low > 0 && low <= len(row) && row[low-1].IsWide() &&
+		(low == len(row) || row[low].Ch != WideContinuation) {
+		row[low-1].Ch = ' '
+	}
+	if high > 0 && high < len(row) && row[high].Ch == WideContinuation &&
+		!row[high-1].IsWide() {
+		row[high].Ch = ' '
+	}
+}
+
+// repairWideRow blanks every unpaired half of a double-width character
+// in row, after cells were moved around in it.
+func repairWideRow(row []AttrChar) {
+	for x := range row {
+		switch {
+		case row[x].IsWide():
+			if x+1 == len(row) || row[x+1].Ch != WideContinuation {
+				row[x].Ch = ' '
+			}
+		case row[x].Ch == WideContinuation:
+			if x == 0 || !row[x-1].IsWide() {
+				row[x].Ch = ' '
+			}
+		}
+	}
+}
+
+// repairWideAt repairs the double-width characters around the cells
+// from offset start to end of the screen, after they were overwritten.
+func (t *Tty) repairWideAt(start, end int) {
+	if t.Size.X <= 0 || start >= end {
+		return
+	}
+	first, last := start/t.Size.X, (end-1)/t.Size.X
+	if first == last {
+		repairWide(t.editRow(first), start%t.Size.X, end-first*t.Size.X)
+		return
+	}
+	repairWide(t.editRow(first), start%t.Size.X, t.Size.X)
+	repairWide(t.editRow(last), 0, end-last*t.Size.X)
+}